@@ -0,0 +1,153 @@
+package jwtauth
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+)
+
+// AllOf creates an AuthorizationFunc that passes the request only if every
+// one of the given functions passes it. The functions are called in order,
+// and the first failure is returned with a "policy" metadata field, such as
+// "all[1]", that identifies the function that failed.
+//
+// AllOf with no functions always passes the request.
+func AllOf(fns ...AuthorizationFunc) AuthorizationFunc {
+	return func(ctx context.Context, claims Claims) error {
+		for i, fn := range fns {
+			if err := fn(ctx, claims); err != nil {
+				return annotatePolicy(err, fmt.Sprintf("all[%d]", i))
+			}
+		}
+		return nil
+	}
+}
+
+// AnyOf creates an AuthorizationFunc that passes the request if at least one
+// of the given functions passes it. The functions are called in order until
+// one passes. If all of them fail, AnyOf responds with ErrAuthorizationFailed
+// whose "failures" metadata field describes each function's failure.
+//
+// Errors that do not indicate an authorization failure, such as
+// ErrUnsupported or any error that is not a goa error, are returned
+// immediately.
+//
+// AnyOf with no functions always fails.
+func AnyOf(fns ...AuthorizationFunc) AuthorizationFunc {
+	return func(ctx context.Context, claims Claims) error {
+		failures := make([]string, 0, len(fns))
+		for i, fn := range fns {
+			err := fn(ctx, claims)
+			if err == nil {
+				return nil
+			}
+			if !isDenial(err) {
+				return annotatePolicy(err, fmt.Sprintf("any[%d]", i))
+			}
+			failures = append(failures, fmt.Sprintf("any[%d]: %s", i, describePolicyError(err)))
+		}
+		return ErrAuthorizationFailed("no policy passed", "failures", failures)
+	}
+}
+
+// NoneOf creates an AuthorizationFunc that passes the request only if none of
+// the given functions pass it; NoneOf(fn) negates fn, e.g. to exclude certain
+// principals. If any function passes, NoneOf responds with
+// ErrAuthorizationFailed whose "policy" metadata field, such as "none[0]",
+// identifies that function.
+//
+// Errors that do not indicate an authorization failure, such as
+// ErrUnsupported or any error that is not a goa error, are returned
+// immediately.
+func NoneOf(fns ...AuthorizationFunc) AuthorizationFunc {
+	return func(ctx context.Context, claims Claims) error {
+		for i, fn := range fns {
+			err := fn(ctx, claims)
+			if err == nil {
+				return ErrAuthorizationFailed("excluded policy passed", "policy", fmt.Sprintf("none[%d]", i))
+			}
+			if !isDenial(err) {
+				return annotatePolicy(err, fmt.Sprintf("none[%d]", i))
+			}
+		}
+		return nil
+	}
+}
+
+// If creates an AuthorizationFunc that applies one of two functions depending
+// on a condition, which is itself an AuthorizationFunc that holds if it
+// passes the request. For example, to require the "audit" scope only of
+// tokens that belong to a tenant:
+//
+//     jwtauth.If(isTenantUser, requireAudit, nil)
+//
+// If then or otherwise is nil, the request passes in that case. If the
+// condition fails with an error that does not indicate an authorization
+// failure, such as ErrUnsupported or any error that is not a goa error, that
+// error is returned and neither branch is applied.
+func If(cond, then, otherwise AuthorizationFunc) AuthorizationFunc {
+	return func(ctx context.Context, claims Claims) error {
+		fn, policy := then, "then"
+		if err := cond(ctx, claims); err != nil {
+			if !isDenial(err) {
+				return err
+			}
+			fn, policy = otherwise, "else"
+		}
+		if fn == nil {
+			return nil
+		}
+		if err := fn(ctx, claims); err != nil {
+			return annotatePolicy(err, policy)
+		}
+		return nil
+	}
+}
+
+// isDenial determines whether err indicates that an AuthorizationFunc denied
+// the request, as opposed to being unable to decide, e.g. because of a
+// configuration problem or a failed database query. Only goa errors with a 4xx
+// status, such as ErrAuthorizationFailed, are denials; combinators return any
+// other error unchanged, so that they never fail open.
+func isDenial(err error) bool {
+	gerr := errorResponse(err)
+	return gerr != nil && gerr.Status >= 400 && gerr.Status < 500
+}
+
+// describePolicyError describes why an AuthorizationFunc failed, including
+// the sub-policy that failed, if known.
+func describePolicyError(err error) string {
+	gerr := errorResponse(err)
+	if gerr == nil {
+		return err.Error()
+	}
+	if policy, ok := gerr.Meta["policy"]; ok {
+		return fmt.Sprintf("%s (%v)", gerr.Detail, policy)
+	}
+	return gerr.Detail
+}
+
+// annotatePolicy returns a copy of a goa error whose "policy" metadata field
+// is prefixed with the given policy, so that the field describes the path to
+// the sub-policy that failed. Other errors are returned unchanged.
+func annotatePolicy(err error, policy string) error {
+	gerr := errorResponse(err)
+	if gerr == nil {
+		return err
+	}
+
+	annotated := *gerr
+	annotated.Meta = make(map[string]interface{}, len(gerr.Meta)+1)
+	for k, v := range gerr.Meta {
+		annotated.Meta[k] = v
+	}
+	if inner, ok := gerr.Meta["policy"]; ok {
+		policy = fmt.Sprintf("%s.%v", policy, inner)
+	}
+	annotated.Meta["policy"] = policy
+
+	if te, ok := err.(*TokenError); ok {
+		return &TokenError{ErrorResponse: &annotated, Reason: te.Reason}
+	}
+	return &annotated
+}
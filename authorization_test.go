@@ -0,0 +1,161 @@
+package jwtauth_test
+
+import (
+	"errors"
+
+	"golang.org/x/net/context"
+
+	"github.com/goadesign/goa"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rightscale/goa-jwtauth"
+)
+
+var _ = Describe("Authorization combinators", func() {
+	ctx := context.Background()
+	claims := jwtauth.NewClaims("sub", "bob")
+
+	pass := func(context.Context, jwtauth.Claims) error {
+		return nil
+	}
+	deny := func(context.Context, jwtauth.Claims) error {
+		return jwtauth.ErrAuthorizationFailed("denied")
+	}
+	broken := func(context.Context, jwtauth.Claims) error {
+		return jwtauth.ErrUnsupported("broken")
+	}
+	errDatabase := errors.New("database is down")
+	failing := func(context.Context, jwtauth.Claims) error {
+		return errDatabase
+	}
+
+	policy := func(err error) interface{} {
+		var gerr *goa.ErrorResponse
+		Ω(errors.As(err, &gerr)).Should(BeTrue())
+		return gerr.Meta["policy"]
+	}
+
+	Context("AllOf()", func() {
+		It("passes when all pass", func() {
+			Ω(jwtauth.AllOf(pass, pass)(ctx, claims)).Should(Succeed())
+			Ω(jwtauth.AllOf()(ctx, claims)).Should(Succeed())
+		})
+
+		It("reports the first failure", func() {
+			called := false
+			last := func(context.Context, jwtauth.Claims) error {
+				called = true
+				return nil
+			}
+
+			err := jwtauth.AllOf(pass, deny, last)(ctx, claims)
+			Ω(err).Should(HaveResponseStatus(403))
+			Ω(policy(err)).Should(Equal("all[1]"))
+			Ω(called).Should(BeFalse())
+		})
+
+		It("preserves the failure's class", func() {
+			err := jwtauth.AllOf(broken)(ctx, claims)
+			Ω(err).Should(HaveResponseStatus(500))
+		})
+
+		It("describes nested failures", func() {
+			err := jwtauth.AllOf(pass, jwtauth.AllOf(pass, pass, deny))(ctx, claims)
+			Ω(policy(err)).Should(Equal("all[1].all[2]"))
+		})
+	})
+
+	Context("AnyOf()", func() {
+		It("passes when any passes", func() {
+			Ω(jwtauth.AnyOf(deny, pass)(ctx, claims)).Should(Succeed())
+		})
+
+		It("reports every failure", func() {
+			err := jwtauth.AnyOf(deny, jwtauth.AllOf(pass, deny))(ctx, claims)
+			Ω(err).Should(HaveResponseStatus(403))
+
+			var gerr *goa.ErrorResponse
+			Ω(errors.As(err, &gerr)).Should(BeTrue())
+			Ω(gerr.Meta["failures"]).Should(Equal([]string{
+				"any[0]: denied",
+				"any[1]: denied (all[1])",
+			}))
+		})
+
+		It("fails with no policies", func() {
+			Ω(jwtauth.AnyOf()(ctx, claims)).Should(HaveResponseStatus(403))
+		})
+
+		It("stops at errors that are not denials", func() {
+			err := jwtauth.AnyOf(broken, pass)(ctx, claims)
+			Ω(err).Should(HaveResponseStatus(500))
+			Ω(policy(err)).Should(Equal("any[0]"))
+
+			Ω(jwtauth.AnyOf(failing, pass)(ctx, claims)).Should(Equal(errDatabase))
+		})
+	})
+
+	Context("NoneOf()", func() {
+		It("inverts the result", func() {
+			Ω(jwtauth.NoneOf(deny)(ctx, claims)).Should(Succeed())
+			Ω(jwtauth.NoneOf(deny, deny)(ctx, claims)).Should(Succeed())
+
+			err := jwtauth.NoneOf(deny, pass)(ctx, claims)
+			Ω(err).Should(HaveResponseStatus(403))
+			Ω(policy(err)).Should(Equal("none[1]"))
+		})
+
+		It("does not invert errors that are not denials", func() {
+			Ω(jwtauth.NoneOf(broken)(ctx, claims)).Should(HaveResponseStatus(500))
+		})
+
+		It("fails closed on errors that are not goa errors", func() {
+			Ω(jwtauth.NoneOf(failing)(ctx, claims)).Should(Equal(errDatabase))
+			Ω(jwtauth.NoneOf(deny, failing)(ctx, claims)).Should(Equal(errDatabase))
+		})
+	})
+
+	Context("If()", func() {
+		It("applies then when the condition holds", func() {
+			err := jwtauth.If(pass, deny, pass)(ctx, claims)
+			Ω(err).Should(HaveResponseStatus(403))
+			Ω(policy(err)).Should(Equal("then"))
+		})
+
+		It("applies otherwise when the condition fails", func() {
+			Ω(jwtauth.If(deny, deny, pass)(ctx, claims)).Should(Succeed())
+
+			err := jwtauth.If(deny, pass, deny)(ctx, claims)
+			Ω(policy(err)).Should(Equal("else"))
+		})
+
+		It("passes when the branch is nil", func() {
+			Ω(jwtauth.If(deny, deny, nil)(ctx, claims)).Should(Succeed())
+			Ω(jwtauth.If(pass, nil, deny)(ctx, claims)).Should(Succeed())
+		})
+
+		It("returns errors from the condition that are not denials", func() {
+			Ω(jwtauth.If(broken, pass, pass)(ctx, claims)).Should(HaveResponseStatus(500))
+		})
+
+		It("fails closed on conditions that are not goa errors", func() {
+			Ω(jwtauth.If(failing, deny, pass)(ctx, claims)).Should(Equal(errDatabase))
+			Ω(jwtauth.If(failing, pass, pass)(ctx, claims)).Should(Equal(errDatabase))
+		})
+	})
+
+	It("composes with DefaultAuthorization", func() {
+		isBob := func(ctx context.Context, claims jwtauth.Claims) error {
+			if claims.Subject() != "bob" {
+				return jwtauth.ErrAuthorizationFailed("not bob")
+			}
+			return nil
+		}
+		scoped := goa.WithRequiredScopes(ctx, []string{"read"})
+		authz := jwtauth.AnyOf(jwtauth.DefaultAuthorization, isBob)
+
+		Ω(authz(scoped, claims)).Should(Succeed())
+		Ω(authz(scoped, jwtauth.NewClaims("sub", "alice"))).Should(HaveResponseStatus(403))
+		Ω(authz(scoped, jwtauth.NewClaims("sub", "alice", "scopes", []string{"read"}))).Should(Succeed())
+	})
+})
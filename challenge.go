@@ -0,0 +1,81 @@
+package jwtauth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/goadesign/goa"
+	"golang.org/x/net/context"
+)
+
+// setChallenge adds an RFC 6750 WWW-Authenticate challenge to the response if
+// err is an authentication or authorization failure.
+func setChallenge(ctx context.Context, oo *mwopts, rw http.ResponseWriter, err error) {
+	if ch := challenge(ctx, oo, err); ch != "" {
+		rw.Header().Set("WWW-Authenticate", ch)
+	}
+}
+
+// challenge builds an RFC 6750 Bearer challenge that describes err. It returns
+// "" if err is not an ErrInvalidRequest, ErrInvalidToken,
+// ErrAuthenticationFailed or ErrAuthorizationFailed.
+//
+// Per RFC 6750 Section 3.1, a challenge for a request that carried no token
+// includes no error code, and "insufficient_scope" describes only requests
+// whose token lacks the required scopes; other authorization failures, such
+// as a policy that denies the request, are described by no error code.
+func challenge(ctx context.Context, oo *mwopts, err error) string {
+	gerr := errorResponse(err)
+	if gerr == nil {
+		return ""
+	}
+
+	var params []string
+	if oo.Realm != "" {
+		params = append(params, challengeParam("realm", oo.Realm))
+	}
+
+	switch gerr.Code {
+	case "invalid_request":
+		params = append(params,
+			challengeParam("error", "invalid_request"),
+			challengeParam("error_description", gerr.Detail))
+	case "invalid_token", "authentication_failed":
+		if te, ok := err.(*TokenError); !ok || te.Reason != ErrTokenMissing {
+			params = append(params,
+				challengeParam("error", "invalid_token"),
+				challengeParam("error_description", gerr.Detail))
+		}
+	case "authorization_failed":
+		// every verified token has claims, since it must name its issuer
+		if gerr.Detail == missingScopes && len(ContextClaims(ctx)) > 0 {
+			params = append(params,
+				challengeParam("error", "insufficient_scope"),
+				challengeParam("error_description", gerr.Detail))
+			if scopes := goa.ContextRequiredScopes(ctx); len(scopes) > 0 {
+				params = append(params, challengeParam("scope", strings.Join(scopes, " ")))
+			}
+		}
+	default:
+		return ""
+	}
+
+	if len(params) == 0 {
+		return "Bearer"
+	}
+	return "Bearer " + strings.Join(params, ", ")
+}
+
+// challengeParam formats an auth-param whose value is a quoted-string. RFC
+// 6750 forbids quotes, backslashes and non-printable characters in attribute
+// values, so they are removed.
+func challengeParam(name, value string) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7E || r == '"' || r == '\\' {
+			return -1
+		}
+		return r
+	}, value)
+	return fmt.Sprintf(`%s="%s"`, name, value)
+}
@@ -0,0 +1,128 @@
+package jwtauth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"golang.org/x/net/context"
+
+	"github.com/goadesign/goa"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rightscale/goa-jwtauth"
+)
+
+var _ = Describe("WWW-Authenticate challenges", func() {
+	var resp *httptest.ResponseRecorder
+	var req *http.Request
+	var stack goa.Handler
+	var ctx context.Context
+
+	BeforeEach(func() {
+		resp = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "http://example.com/", nil)
+		ctx = goa.WithRequiredScopes(context.Background(), []string{"bottle:drink", "bottle:open"})
+		stack = func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			return nil
+		}
+	})
+
+	challenge := func() string {
+		return resp.Header().Get("WWW-Authenticate")
+	}
+
+	It("describes invalid tokens", func() {
+		middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1}, jwtauth.Realm("cellar"))
+		setBearerHeader(req, makeToken("alice", "bob", hmacKey2))
+
+		middleware(stack)(ctx, resp, req)
+
+		Ω(challenge()).Should(HavePrefix(`Bearer realm="cellar", error="invalid_token", error_description="`))
+	})
+
+	It("describes insufficient scopes", func() {
+		middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1})
+		setBearerHeader(req, makeToken("alice", "bob", hmacKey1, "bottle:drink"))
+
+		middleware(stack)(ctx, resp, req)
+
+		Ω(challenge()).Should(HavePrefix(`Bearer error="insufficient_scope", error_description="`))
+		Ω(challenge()).Should(HaveSuffix(`, scope="bottle:drink bottle:open"`))
+	})
+
+	It("omits the error code when the token is missing", func() {
+		middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1}, jwtauth.Required(), jwtauth.Realm("cellar"))
+
+		middleware(stack)(ctx, resp, req)
+
+		Ω(challenge()).Should(Equal(`Bearer realm="cellar"`))
+	})
+
+	It("omits the error code when an optional token is missing", func() {
+		middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1}, jwtauth.Realm("cellar"))
+
+		result := middleware(stack)(ctx, resp, req)
+
+		Ω(result).Should(HaveResponseStatus(403))
+		Ω(challenge()).Should(Equal(`Bearer realm="cellar"`))
+	})
+
+	It("omits the error code when a policy denies the request", func() {
+		deny := func(context.Context, jwtauth.Claims) error {
+			return jwtauth.ErrAuthorizationFailed("policy denied")
+		}
+		middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1},
+			jwtauth.Authorization(deny), jwtauth.Realm("cellar"))
+		setBearerHeader(req, makeToken("alice", "bob", hmacKey1, "bottle:drink", "bottle:open"))
+
+		result := middleware(stack)(ctx, resp, req)
+
+		Ω(result).Should(HaveResponseStatus(403))
+		Ω(challenge()).Should(Equal(`Bearer realm="cellar"`))
+	})
+
+	It("takes the realm from the security scheme", func() {
+		scheme := &goa.JWTSecurity{In: goa.LocHeader, Name: "Authorization", Description: "cellar"}
+		middleware := jwtauth.New(scheme, &jwtauth.SimpleKeystore{hmacKey1}, jwtauth.Required())
+
+		middleware(stack)(ctx, resp, req)
+
+		Ω(challenge()).Should(Equal(`Bearer realm="cellar"`))
+
+		resp = httptest.NewRecorder()
+		middleware = jwtauth.New(scheme, &jwtauth.SimpleKeystore{hmacKey1}, jwtauth.Required(), jwtauth.Realm("wine"))
+
+		middleware(stack)(ctx, resp, req)
+
+		Ω(challenge()).Should(Equal(`Bearer realm="wine"`))
+	})
+
+	It("removes illegal characters", func() {
+		auth := func(context.Context, jwtauth.Claims) error {
+			return jwtauth.ErrInvalidRequest(`say "please"`)
+		}
+		middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1}, jwtauth.Authorization(auth))
+
+		middleware(stack)(context.Background(), resp, req)
+
+		Ω(challenge()).Should(Equal(`Bearer error="invalid_request", error_description="say please"`))
+	})
+
+	It("does not challenge successful requests", func() {
+		middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1})
+		setBearerHeader(req, makeToken("alice", "bob", hmacKey1, "bottle:drink", "bottle:open"))
+
+		Ω(middleware(stack)(ctx, resp, req)).ShouldNot(HaveOccurred())
+
+		Ω(challenge()).Should(Equal(""))
+	})
+
+	It("does not challenge configuration errors", func() {
+		middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{rsaKey1})
+		setBearerHeader(req, makeToken("alice", "bob", rsaKey1))
+
+		middleware(stack)(ctx, resp, req)
+
+		Ω(challenge()).Should(Equal(""))
+	})
+})
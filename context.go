@@ -1,12 +1,20 @@
 package jwtauth
 
-import "golang.org/x/net/context"
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+)
 
 type contextKey int
 
 const (
 	claimsKey contextKey = iota + 1
 	principalKey
+	tokenSourceKey
+	sourceRecorderKey
+	optionsKey
+	requestKey
 )
 
 // WithClaims creates a child context containing the given claims.
@@ -19,3 +27,57 @@ func ContextClaims(ctx context.Context) Claims {
 	claims, _ := ctx.Value(claimsKey).(Claims)
 	return claims
 }
+
+// WithPrincipal creates a child context containing the given principal.
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// ContextPrincipal retrieves the principal associated with the request. It
+// returns nil if the request carried no JWT.
+func ContextPrincipal(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey).(*Principal)
+	return principal
+}
+
+// withTokenSource creates a child context containing the name of the source
+// from which the request's JWT was extracted.
+func withTokenSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, tokenSourceKey, source)
+}
+
+// ContextTokenSource retrieves the name of the source from which the
+// request's JWT was extracted, e.g. "header:Authorization" or "cookie:jwt".
+// It returns "" if the request carried no JWT, or if the ExtractionFunc did
+// not report a source.
+func ContextTokenSource(ctx context.Context) string {
+	source, _ := ctx.Value(tokenSourceKey).(string)
+	return source
+}
+
+// withOptions creates a child context containing the options of the
+// middleware that is handling the request, so that the default behaviors can
+// honor them.
+func withOptions(ctx context.Context, oo *mwopts) context.Context {
+	return context.WithValue(ctx, optionsKey, oo)
+}
+
+// contextOptions retrieves the options of the middleware that is handling the
+// request, or nil if there are none.
+func contextOptions(ctx context.Context) *mwopts {
+	oo, _ := ctx.Value(optionsKey).(*mwopts)
+	return oo
+}
+
+// withRequest creates a child context containing the request that the
+// middleware is handling.
+func withRequest(ctx context.Context, req *http.Request) context.Context {
+	return context.WithValue(ctx, requestKey, req)
+}
+
+// contextRequest retrieves the request that the middleware is handling, or nil
+// if there is none.
+func contextRequest(ctx context.Context) *http.Request {
+	req, _ := ctx.Value(requestKey).(*http.Request)
+	return req
+}
@@ -1,6 +1,8 @@
 package jwtauth
 
 import (
+	"strings"
+
 	"github.com/goadesign/goa"
 	"golang.org/x/net/context"
 )
@@ -9,31 +11,84 @@ import (
 // that jwtauth uses to store scope information in tokens. If you need to
 // interoperate with third parties w/r/t to token scope, it may be advisable
 // to change this to a Collision-Resistant Claim Name instead.
+//
+// ScopesClaim applies to every middleware that does not use the ScopeClaims()
+// option. Prefer the option, which can be set per middleware and can name
+// several claims, e.g. the OAuth2 "scope" claim.
 var ScopesClaim = "scopes"
 
 // DefaultAuthorization is the default authorization method. It compares the
 // context's required scopes against a list of scopes that are claimed in the
-// JWT. If the claimed scopes satisfy all required scopes, DefaultAuthorization
-// passes the request; otherwise, it responds with ErrAuthorizationFailed.
+// JWT, as determined by ClaimedScopes(). If the claimed scopes satisfy all
+// required scopes, DefaultAuthorization passes the request; otherwise, it
+// responds with ErrAuthorizationFailed.
+//
+// By default, a claimed scope satisfies only an identical required scope; use
+// the ScopeMatching() option to select a different ScopeMatcher.
 //
 // If the context requires no scopes, DefaultAuthorization always passes
 // the request.
 func DefaultAuthorization(ctx context.Context, claims Claims) error {
+	return authorizeScopes(ctx, ClaimedScopes(ctx, claims))
+}
+
+// missingScopes is the detail of the error that authorizeScopes returns, by
+// which challenges recognize a lack of scopes.
+const missingScopes = "missing scopes"
+
+// authorizeScopes compares the context's required scopes against the held
+// scopes.
+func authorizeScopes(ctx context.Context, held []string) error {
 	reqd := goa.ContextRequiredScopes(ctx)
 
-	held := claims.Strings(ScopesClaim)
+	if missing := satisfiesAll(scopeMatcher(ctx), held, reqd); len(missing) > 0 {
+		return ErrAuthorizationFailed(missingScopes, "required", reqd)
+	}
+	return nil
+}
+
+// scopeMatcher returns the ScopeMatcher of the middleware that is handling
+// the request, or ExactScopes if none was selected.
+func scopeMatcher(ctx context.Context) ScopeMatcher {
+	if oo := contextOptions(ctx); oo != nil && oo.ScopeMatcher != nil {
+		return oo.ScopeMatcher
+	}
+	return ExactScopes
+}
 
-	for _, r := range reqd {
-		found := false
-		for _, h := range held {
-			found = found || (h == r)
-			if found {
-				break
-			}
+// ClaimedScopes returns the scopes that are claimed in a JWT. It reads the
+// claims named by the ScopeClaims() option of the middleware that is handling
+// the request or, by default, the ScopesClaim. A claim may be either an array
+// of scopes, or a string of space-delimited scopes as described in RFC 8693
+// Section 4.2 (e.g. the OAuth2 "scope" claim). Scopes that appear in several
+// claims are returned once.
+func ClaimedScopes(ctx context.Context, claims Claims) []string {
+	names := []string{ScopesClaim}
+	if oo := contextOptions(ctx); oo != nil && len(oo.ScopeClaims) > 0 {
+		names = oo.ScopeClaims
+	}
+	return claimList(claims, names...)
+}
+
+// claimList combines the values of the named claims, each of which may be an
+// array or a space-delimited string. Values that appear in several claims are
+// returned once.
+func claimList(claims Claims, names ...string) []string {
+	var list []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		var values []string
+		if s, ok := claims[name].(string); ok {
+			values = strings.Fields(s)
+		} else {
+			values = claims.Strings(name)
 		}
-		if !found {
-			return ErrAuthorizationFailed("missing scopes", "required", reqd)
+		for _, v := range values {
+			if !seen[v] {
+				seen[v] = true
+				list = append(list, v)
+			}
 		}
 	}
-	return nil
+	return list
 }
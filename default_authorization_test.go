@@ -63,3 +63,63 @@ var _ = Describe("DefaultAuthorization()", func() {
 		})
 	})
 })
+
+var _ = Describe("ClaimedScopes()", func() {
+	claims := jwtauth.NewClaims(
+		"scopes", []interface{}{"read", "write"},
+		"scope", "read drink  eat",
+		"scp", "admin",
+	)
+
+	It("reads the ScopesClaim by default", func() {
+		Ω(jwtauth.ClaimedScopes(context.Background(), claims)).Should(Equal([]string{"read", "write"}))
+	})
+
+	Context("in a middleware", func() {
+		var resp *httptest.ResponseRecorder
+		var req *http.Request
+		var held []string
+
+		stack := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+			held = jwtauth.ClaimedScopes(ctx, jwtauth.ContextClaims(ctx))
+			return nil
+		}
+
+		BeforeEach(func() {
+			resp = httptest.NewRecorder()
+			req, _ = http.NewRequest("GET", "http://example.com/", nil)
+			held = nil
+
+			token, err := jwtauth.NewToken(hmacKey1, claims)
+			Ω(err).ShouldNot(HaveOccurred())
+			setBearerHeader(req, token)
+		})
+
+		It("splits space-delimited claims", func() {
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1},
+				jwtauth.ScopeClaims("scope"))
+
+			Ω(middleware(stack)(context.Background(), resp, req)).Should(Succeed())
+			Ω(held).Should(Equal([]string{"read", "drink", "eat"}))
+		})
+
+		It("combines several claims", func() {
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1},
+				jwtauth.ScopeClaims("scopes", "scope", "scp"))
+
+			Ω(middleware(stack)(context.Background(), resp, req)).Should(Succeed())
+			Ω(held).Should(Equal([]string{"read", "write", "drink", "eat", "admin"}))
+		})
+
+		It("authorizes using the named claims", func() {
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1},
+				jwtauth.ScopeClaims("scp"))
+			ctx := goa.WithRequiredScopes(context.Background(), []string{"admin"})
+
+			Ω(middleware(stack)(ctx, resp, req)).Should(Succeed())
+
+			ctx = goa.WithRequiredScopes(context.Background(), []string{"write"})
+			Ω(middleware(stack)(ctx, resp, req)).Should(HaveResponseStatus(403))
+		})
+	})
+})
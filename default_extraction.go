@@ -1,30 +1,148 @@
 package jwtauth
 
 import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/goadesign/goa"
 )
 
-// DefaultExtraction is the default token-extraction method. It finds the header
-// named in the security scheme, discards an optional one-word prefix such as
-// "Bearer" or "JWT", and returns the remainder of the header value.
+const (
+	// LocCookie is a goa.Location that DefaultExtraction understands in
+	// addition to goa.LocHeader and goa.LocQuery. It indicates that the JWT
+	// is the value of the cookie named by the security scheme.
+	LocCookie goa.Location = "cookie"
+
+	// LocForm is a goa.Location that DefaultExtraction understands in
+	// addition to goa.LocHeader and goa.LocQuery. It indicates that the JWT
+	// is a parameter of a form-encoded request body, as described in RFC 6750
+	// Section 2.2; the security scheme names the parameter, which is usually
+	// "access_token".
+	LocForm goa.Location = "form"
+)
+
+// maxFormSize is the largest form-encoded request body in which extractForm
+// looks for a JWT; it matches the limit of http.Request.ParseForm().
+const maxFormSize = 10 << 20
+
+// defaultPrefixes are the header prefixes that DefaultExtraction accepts.
+var defaultPrefixes = []string{"Bearer", "JWT"}
+
+// DefaultExtraction is the default token-extraction method. It finds the JWT
+// in the location named in the security scheme:
+//
+// For goa.LocHeader, it finds the named header, discards an optional "Bearer"
+// or "JWT" prefix, and returns the remainder of the header value. This is
+// compatible with OAuth2 bearer-token and other schemes that use the
+// Authorization header to transmit a JWT. A header with any other prefix, such
+// as "Basic", carries no JWT and is ignored.
+//
+// For goa.LocQuery, it returns the value of the named query-string parameter.
+//
+// For LocCookie, it returns the value of the named cookie.
 //
-// DefaultExtraction is compatible with OAuth2 bearer-token and other schemes
-// that use the Authorization header to transmit a JWT.
+// For LocForm, it returns the named parameter of a request whose body is
+// form-encoded. It reads the body and then restores it, so that the next
+// handler can read it too. However, goa reads the body of actions that have a
+// payload before any middleware runs, so LocForm finds no JWT in their
+// requests; use it only with actions that have no payload.
 func DefaultExtraction(scheme *goa.JWTSecurity, req *http.Request) (string, error) {
-	var header string
+	var tok string
 	switch scheme.In {
 	case goa.LocHeader:
-		header = req.Header.Get(scheme.Name)
+		tok = extractHeader(req, scheme.Name, true, defaultPrefixes)
+	case goa.LocQuery:
+		tok = extractQuery(req, scheme.Name)
+	case LocCookie:
+		tok = extractCookie(req, scheme.Name)
+	case LocForm:
+		tok = extractForm(req, scheme.Name)
 	default:
-		return "", ErrUnsupported("unexpected goa.JWTSecurity.In", "expected", goa.LocHeader, "got", scheme.In)
+		return "", ErrUnsupported("unexpected goa.JWTSecurity.In", "expected", []goa.Location{goa.LocHeader, goa.LocQuery, LocCookie, LocForm}, "got", scheme.In)
 	}
 
+	if tok != "" {
+		recordSource(req, sourceName(scheme.In, scheme.Name))
+	}
+	return tok, nil
+}
+
+// sourceName describes a token source for ContextTokenSource.
+func sourceName(in goa.Location, name string) string {
+	return string(in) + ":" + name
+}
+
+// extractHeader returns the token in the named header. The header value may be
+// a bare token if bare is true, or one of the prefixes (compared without regard
+// to case) followed by a space and the token; otherwise, it carries no token.
+func extractHeader(req *http.Request, name string, bare bool, prefixes []string) string {
+	header := strings.TrimSpace(req.Header.Get(name))
+	if header == "" {
+		return ""
+	}
 	bits := strings.SplitN(header, " ", 2)
 	if len(bits) == 1 {
-		return bits[0], nil
+		if bare {
+			return bits[0]
+		}
+		return ""
+	}
+	for _, p := range prefixes {
+		if strings.EqualFold(bits[0], p) {
+			return strings.TrimSpace(bits[1])
+		}
 	}
-	return bits[1], nil
+	return ""
+}
+
+// extractQuery returns the value of the named query-string parameter.
+func extractQuery(req *http.Request, name string) string {
+	return req.URL.Query().Get(name)
+}
+
+// extractCookie returns the value of the named cookie.
+func extractCookie(req *http.Request, name string) string {
+	cookie, err := req.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// extractForm returns the named parameter of a form-encoded request body.
+// Per RFC 6750, the request may not use the GET method. extractForm replaces
+// the request's body with one that yields the same bytes, so that the body is
+// not consumed.
+func extractForm(req *http.Request, name string) string {
+	if req.Method == "GET" || req.Body == nil {
+		return ""
+	}
+	mt, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || mt != "application/x-www-form-urlencoded" {
+		return ""
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxFormSize+1))
+	req.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(body), req.Body), Closer: req.Body}
+	if err != nil || len(body) > maxFormSize {
+		return ""
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return ""
+	}
+	return form.Get(name)
+}
+
+// replayBody is a request body that yields bytes that were already read from
+// the original body before the rest of it.
+type replayBody struct {
+	io.Reader
+	io.Closer
 }
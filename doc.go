@@ -1,8 +1,8 @@
 /*
 Package jwtauth provides a middleware for the Goa framework that parses and
 validates JSON Web Tokens (JWTs) that appear in requests, then adds them
-to the request context. It supports any JWT algorithm that uses RSA, ECDSA
-or HMAC.
+to the request context. It supports any JWT algorithm that uses RSA, ECDSA,
+Ed25519 (EdDSA) or HMAC.
 
 When you setup your goa.Service, install the jwtauth middleware:
 
@@ -24,6 +24,40 @@ contains all of the scopes that are required by your action, as determined by
 goa.ContextRequiredScopes(). If anything is missing, jwtauth returns 4xx or 5xx
 error with a detailed message.
 
+By default, requests without a JWT are passed to the authorization function
+with empty claims, so actions that require no scopes are public. To reject
+every request that lacks a JWT, use the Required() option:
+
+		middleware := jwtauth.New(scheme, store, jwtauth.Required())
+
+
+Plain HTTP Handlers
+
+For routes that are mounted outside of goa, such as health checks or webhooks,
+NewHTTP() creates an equivalent middleware for any net/http handler. It accepts
+the same scheme, keystore and options as New():
+
+		handler = jwtauth.NewHTTP(scheme, store)(handler)
+
+The next handler can call ContextClaims(req.Context()) to obtain the claims.
+
+
+gRPC Services
+
+The interceptors of the grpcauth subpackage apply the same checks to gRPC
+calls. The token is read from the "authorization" metadata key, and the scopes
+that each method requires are given as a map of full method names:
+
+		scopes := map[string][]string{"/bottles.Cellar/Drink": {"drink"}}
+		server := grpc.NewServer(
+			grpc.UnaryInterceptor(grpcauth.NewUnaryInterceptor(store, scopes, grpcauth.DenyUnmapped())),
+			grpc.StreamInterceptor(grpcauth.NewStreamInterceptor(store, scopes, grpcauth.DenyUnmapped())),
+		)
+
+Rejected calls fail with the Unauthenticated or PermissionDenied status code.
+Beware that, without the DenyUnmapped() option, methods that are missing from
+the map require no scopes at all.
+
 
 Multiple Issuers
 
@@ -46,6 +80,95 @@ Using a NamedKeystore, you can grant or revoke trust at any time, even while
 the application is running, and your changes will take effect on the next
 request.
 
+An issuer may also have several keys at once, each with its own Key ID. When
+a JWT's header contains a "kid" parameter, the middleware verifies it using
+the issuer's key with that ID or, if there is no such key, the key that was
+trusted without an ID; otherwise, it tries the issuer's keys from the most
+to the least recently trusted, up to eight of them. This allows old and new
+keys to overlap during key rotation:
+
+		store.TrustKey("us.acme.com", "2016-10", usKey)
+		store.TrustKey("us.acme.com", "2016-11", usNextKey)
+
+To load a directory of PEM files named after their issuers, such as
+us.acme.com.pem, use LoadKeyDir(). Unlike LoadKey(), ParseKey(), LoadKeyFile()
+and LoadKeyDir() return an error if a key is malformed or is not of the type
+you expect:
+
+		store := &jwtauth.NamedKeystore{}
+		if err := jwtauth.LoadKeyDir(store, "/etc/acme/keys", jwtauth.RSAKey); err != nil {
+			panic(err)
+		}
+
+A single PEM file may also hold a bundle of keys, such as an issuer's current
+and next keys. Each block may name its issuer and Key ID in "Issuer" and "Kid"
+headers; LoadKeyBundle() returns every key in the bundle, and TrustKeyBundle()
+trusts them, using a default issuer for blocks that name none:
+
+		material, _ := ioutil.ReadFile("acme.pem")
+		keys, err := jwtauth.LoadKeyBundle(material)
+		if err == nil {
+			err = jwtauth.TrustKeyBundle(store, "us.acme.com", keys)
+		}
+
+
+Key Sets
+
+If your issuer publishes its keys as a JSON Web Key Set (RFC 7517), use a
+JWKSKeystore to load the set from a URL or a local file. The keystore refreshes
+the set in the background, and backs off if the set becomes unavailable.
+
+		store := &jwtauth.JWKSKeystore{
+			Issuer:   "acme.com",
+			URL:      "https://acme.com/.well-known/jwks.json",
+			Interval: 15 * time.Minute,
+		}
+		if err := store.Start(); err != nil {
+			panic(err)
+		}
+		defer store.Stop()
+
+		middleware := jwtauth.New(app.NewJWTSecurity(), store)
+
+
+Algorithms
+
+By default, jwtauth accepts any signing algorithm that suits the issuer's key.
+It never accepts the "none" algorithm, and never verifies an HMAC token using
+an RSA or ECDSA key. To pin the accepted algorithms globally, then narrow
+them further for specific issuers:
+
+		middleware := jwtauth.New(scheme, store,
+			jwtauth.Algorithms("RS256", "ES256"),
+			jwtauth.IssuerAlgorithms("us.acme.com", "ES256"),
+		)
+
+LoadKey() parses PKCS1, PKCS8 and PKIX keys as well as X.509 certificates,
+whose public key is used to verify tokens. Use LoadCertificateKey() to also
+ensure that a certificate is currently valid:
+
+		key, err := jwtauth.LoadCertificateKey(pem, time.Now())
+
+
+Audience
+
+To ensure that tokens were issued for use with your service, require their
+"aud" (Audience) claim to contain your service's identifier:
+
+		middleware := jwtauth.New(scheme, store, jwtauth.Audience("bottles.acme.com"))
+
+
+Clock Skew
+
+Tokens are rejected if they have expired, or are not yet valid, according to
+the "exp", "nbf" and "iat" claims. If the clocks of your issuers and services
+are not perfectly synchronized, allow some leeway:
+
+		middleware := jwtauth.New(scheme, store, jwtauth.Leeway(30*time.Second))
+
+The Clock() option overrides the source of the current time, which can make
+tests deterministic.
+
 
 Custom Authorization
 
@@ -77,6 +200,114 @@ to ensure that the user is alive and well:
 		}
 
 
+Combining Policies
+
+AllOf(), AnyOf(), NoneOf() and If() compose several AuthorizationFuncs into
+one, so you can add checks to the default behavior without reimplementing it:
+
+		middleware := jwtauth.New(scheme, store,
+			jwtauth.Authorization(jwtauth.AnyOf(
+				jwtauth.AllOf(jwtauth.DefaultAuthorization, sameTenant),
+				isAdmin,
+			)),
+		)
+
+When a combination fails, the "policy" or "failures" metadata of its
+ErrAuthorizationFailed identifies the sub-policies that failed.
+
+
+To attach rules to specific endpoints, register them in a PolicyRegistry under
+the name of a goa controller and action. The registry applies the default
+behavior to every request, then the rules of the requested action:
+
+		policies := &jwtauth.PolicyRegistry{}
+		policies.Register("bottle", "delete", jwtauth.ClaimEquals("tenant", "acme"))
+		policies.Register("report", jwtauth.AnyAction,
+			jwtauth.TimeWindow(9*time.Hour, 17*time.Hour, time.Local))
+
+		middleware := jwtauth.New(scheme, store, jwtauth.Authorization(policies.Authorize))
+
+
+Some rules depend on the request itself. Install them with the
+RequestAuthorization() option, which the middleware applies after the
+AuthorizationFunc; jwtauth provides rules that bind claims to path segments,
+headers and parameters:
+
+		middleware := jwtauth.New(scheme, store,
+			jwtauth.RequestAuthorization(jwtauth.RequestRules(
+				jwtauth.ClaimMatchesPath("/users/{sub}"),
+				jwtauth.ClaimMatchesHeader("tenant", "X-Tenant"),
+			)),
+		)
+
+RequestPolicy() adapts such rules for use with AllOf() or a PolicyRegistry.
+
+
+Policy Expressions
+
+Rules can also be written as expressions, e.g. to keep them in configuration.
+Compile them once, at startup; CompilePolicy() reports malformed expressions
+and unknown variables. See Policy for the variables and operators.
+
+		policy, err := jwtauth.CompilePolicy(`"bottle:drink" in scopes && claims.tenant == params.tenant`)
+		if err != nil {
+			panic(err)
+		}
+		middleware := jwtauth.New(scheme, store, jwtauth.Authorization(policy.Authorize))
+
+
+Scope Claims
+
+By default, jwtauth reads a token's scopes from its "scopes" claim. Tokens from
+OAuth2 identity providers often carry a space-delimited "scope" claim instead,
+or an "scp" claim; use the ScopeClaims() option to read them:
+
+		middleware := jwtauth.New(scheme, store, jwtauth.ScopeClaims("scope", "scp"))
+
+Custom authorization functions can call ClaimedScopes() to obtain the same list.
+
+
+If your tokens carry roles rather than scopes, RoleAuthorization() expands the
+"roles" claim into scopes using a RoleMapping, which you can load from JSON:
+
+		mapping, err := jwtauth.LoadRoleMapping([]byte(`{"sommelier": ["bottle:drink"]}`))
+		middleware := jwtauth.New(scheme, store,
+			jwtauth.Authorization(jwtauth.RoleAuthorization(mapping)),
+		)
+
+
+Scope Matching
+
+By default, a scope claimed by a token satisfies only an identical required
+scope. If your scopes are hierarchical, select a different ScopeMatcher:
+WildcardScopes lets "bottle:*" satisfy "bottle:drink", and a ScopeGraph
+additionally lets one scope imply others:
+
+		middleware := jwtauth.New(scheme, store,
+			jwtauth.ScopeMatching(jwtauth.ScopeGraph{
+				"admin": {"bottle:*", "user:*"},
+			}),
+		)
+
+
+Principals
+
+Rather than reading raw claims, handlers can call ContextPrincipal() to learn
+who made the request. The middleware builds a Principal from the claims of
+each valid token using DefaultPrincipal(); if your issuer uses different claim
+names, supply your own mapping:
+
+		middleware := jwtauth.New(scheme, store,
+			jwtauth.PrincipalMapping(func(ctx context.Context, claims jwtauth.Claims) (*jwtauth.Principal, error) {
+				p, err := jwtauth.DefaultPrincipal(ctx, claims)
+				if err == nil {
+					p.Tenant = claims.String("org")
+				}
+				return p, err
+			}),
+		)
+
+
 Custom Extraction
 
 You can specialize the logic used to extract a JWT from the request
@@ -94,19 +325,43 @@ by providing the Extraction() option:
 The default extraction behavior, described below, should be sufficient for
 almost any use case.
 
-DefaultExtraction supports only security schemes that use goa.LocHeader;
-JWTs in the query string, or in other locations, are not supported.
+DefaultExtraction looks for the JWT in the location specified by the
+goa.JWTSecurity definition that is used to initialize the middleware. It
+supports goa.LocHeader and goa.LocQuery, as well as two jwtauth-specific
+locations: LocCookie, for browser sessions, and LocForm, for the RFC 6750
+"access_token" parameter of a form-encoded request body. goa reads the body of
+an action that has a payload before any middleware runs, so use LocForm only
+with actions that have no payload.
+
+		scheme := &goa.JWTSecurity{In: jwtauth.LocCookie, Name: "jwt"}
+		middleware := jwtauth.New(scheme, store)
 
-Although jwtauth uses the header name specified by the goa.JWTSecurity definition
-that is used to initialize it, some assumptions are made about the format of
-the header value. It must contain a base64-encoded JWT, which may be prefixed
-by a single-word qualifier. Assuming the security scheme uses the Authorization
-header, any of the following would be acceptable:
+For header locations, jwtauth uses the header name specified by the security
+scheme, but some assumptions are made about the format of the header value. It
+must contain a base64-encoded JWT, which may be prefixed by "Bearer" or "JWT".
+Assuming the security scheme uses the Authorization header, any of the
+following would be acceptable:
 
 		Authorization: <base64_token>
 		Authorization: Bearer <base64_token>
 		Authorization: JWT <base64_token>
-		Authorization: AnyOtherWordHere <base64_token>
+
+A header with any other prefix, such as "Basic", is treated as carrying no JWT.
+
+To accept tokens from several places, or to be strict about the header prefix,
+build an extraction chain. It tries each source in order, and rejects requests
+that carry tokens in more than one source with ErrInvalidRequest:
+
+		middleware := jwtauth.New(scheme, store,
+			jwtauth.Extraction(jwtauth.ExtractionChain(
+				jwtauth.FromHeader("Authorization", "Bearer", "JWT"),
+				jwtauth.FromCookie("jwt"),
+				jwtauth.FromQuery("access_token"),
+			)),
+		)
+
+Your actions can call ContextTokenSource() to learn where the token came from,
+e.g. to apply CSRF protection to cookie-borne tokens.
 
 
 Token Management
@@ -124,17 +379,45 @@ Error Handling
 Common errors are returned as instances of a goa error class, which have
 the effect of responding with a specific HTTP status:
 
-ErrUnsupported (500): the token or configuration uses an unsupported feature.
+ErrUnsupported (500): the token or configuration uses an unsupported feature,
+or the keystore holds a key whose type is unsuitable for the token's algorithm.
+Because these errors indicate a configuration problem, you can use the Alert()
+option to be notified when they occur.
 
-ErrInvalidToken (401): the token is malformed or its signature is bad.
+ErrInvalidRequest (400): the request carried more than one token.
 
-ErrAuthenticationFailed (403): the token is well-formed but the issuer is not
+ErrInvalidToken (401): the token is malformed, its signature is bad, it uses a
+disallowed algorithm or audience, or it is missing but required.
+
+ErrAuthenticationFailed (401): the token is well-formed but the issuer is not
 trusted, it has expired, or is not yet valid.
 
+Note that earlier versions of jwtauth responded to untrusted, expired and
+not-yet-valid tokens with ErrInvalidToken. Clients that test for the
+"invalid_token" error code should also accept "authentication_failed"; both
+produce an RFC 6750 "invalid_token" challenge.
+
 ErrAuthorizationFailed (403): the token is well-formed and valid, but the
 authentication principal did not satisfy all of the scopes required to call
 the requested goa action.
 
+Token failures are returned as a *TokenError, which wraps a specific reason
+such as ErrTokenExpired or ErrTokenUntrusted. Use errors.Is() to test for a
+reason, or errors.As() to obtain the TokenError. TokenErrors never include the
+token itself, so they are safe to return to clients and to log.
+
+		if errors.Is(err, jwtauth.ErrTokenExpired) {
+			// ask the client to refresh its token
+		}
+
+When it returns any of these errors (except ErrUnsupported), the middleware also
+sets an RFC 6750 WWW-Authenticate header, so that OAuth2 clients can tell an
+"invalid_token" from an "insufficient_scope" error. A challenge reports
+"insufficient_scope" only when the token lacks the required scopes; when the
+request carried no token, or another policy denied it, the challenge has no
+error code. The challenges' realm is the description of the goa security
+scheme; use the Realm() option to override it.
+
 
 Testing
 
@@ -0,0 +1,76 @@
+package jwtauth
+
+import (
+	"crypto/ed25519"
+	"errors"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+type (
+	// signingMethodEdDSA implements the RFC 8037 "EdDSA" JWS algorithm for
+	// Ed25519 keys, which dgrijalva/jwt-go does not support natively.
+	signingMethodEdDSA struct{}
+)
+
+// SigningMethodEdDSA signs and verifies tokens using Ed25519 keys. jwtauth
+// registers it with dgrijalva/jwt-go, so that tokens whose "alg" is "EdDSA"
+// can be verified using an ed25519.PublicKey.
+var SigningMethodEdDSA jwt.SigningMethod = &signingMethodEdDSA{}
+
+// errEdDSAVerification indicates that an EdDSA signature is invalid.
+var errEdDSAVerification = errors.New("ed25519: verification error")
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+// Alg returns "EdDSA".
+func (m *signingMethodEdDSA) Alg() string {
+	return "EdDSA"
+}
+
+// Verify checks the signature of signingString using an ed25519.PublicKey.
+func (m *signingMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	var pub ed25519.PublicKey
+	switch kt := key.(type) {
+	case ed25519.PublicKey:
+		pub = kt
+	case *ed25519.PublicKey:
+		pub = *kt
+	default:
+		return jwt.ErrInvalidKeyType
+	}
+	if len(pub) != ed25519.PublicKeySize {
+		return jwt.ErrInvalidKey
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, []byte(signingString), sig) {
+		return errEdDSAVerification
+	}
+	return nil
+}
+
+// Sign signs signingString using an ed25519.PrivateKey.
+func (m *signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	var priv ed25519.PrivateKey
+	switch kt := key.(type) {
+	case ed25519.PrivateKey:
+		priv = kt
+	case *ed25519.PrivateKey:
+		priv = *kt
+	default:
+		return "", jwt.ErrInvalidKeyType
+	}
+	if len(priv) != ed25519.PrivateKeySize {
+		return "", jwt.ErrInvalidKey
+	}
+
+	return jwt.EncodeSegment(ed25519.Sign(priv, []byte(signingString))), nil
+}
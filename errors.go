@@ -1,18 +1,123 @@
 package jwtauth
 
-import "github.com/goadesign/goa"
+import (
+	"errors"
+
+	"github.com/goadesign/goa"
+)
 
 var (
 	// ErrUnsupported indicates that the application is configured to use a
 	// capability that jwtauth does not support.
 	ErrUnsupported = goa.NewErrorClass("unsupported", 500)
 
+	// ErrInvalidRequest indicates that the request carried more than one
+	// JWT, or was otherwise malformed.
+	ErrInvalidRequest = goa.NewErrorClass("invalid_request", 400)
+
 	// ErrInvalidToken indicates that the request's JWT was malformed or
 	// its signature could not be verified.
 	ErrInvalidToken = goa.NewErrorClass("invalid_token", 401)
 
+	// ErrAuthenticationFailed indicates that the request's JWT was
+	// well-formed, but its issuer is not trusted, or it has expired or is not
+	// yet valid.
+	ErrAuthenticationFailed = goa.NewErrorClass("authentication_failed", 401)
+
 	// ErrAuthorizationFailed indicates that the request's JWT was well-formed
 	// and valid, but the user is not authorized to perform the requested
 	// operation.
 	ErrAuthorizationFailed = goa.NewErrorClass("authorization_failed", 403)
 )
+
+// Reasons why the middleware may reject a request's token. The middleware
+// wraps them in a TokenError; test for them with errors.Is().
+var (
+	// ErrTokenMissing indicates that a token is required, but the request
+	// carried none.
+	ErrTokenMissing = errors.New("missing token")
+
+	// ErrTokenAmbiguous indicates that the request carried tokens in more
+	// than one location.
+	ErrTokenAmbiguous = errors.New("ambiguous token")
+
+	// ErrTokenMalformed indicates that the token could not be decoded.
+	ErrTokenMalformed = errors.New("malformed token")
+
+	// ErrTokenSignature indicates that the token's signature could not be
+	// verified with any of its issuer's keys.
+	ErrTokenSignature = errors.New("bad signature")
+
+	// ErrTokenAlgorithm indicates that the token's signing algorithm is not
+	// allowed, or is not suitable for its issuer's keys.
+	ErrTokenAlgorithm = errors.New("algorithm not allowed")
+
+	// ErrTokenUntrusted indicates that the keystore has no key for the
+	// token's issuer (and Key ID, if any).
+	ErrTokenUntrusted = errors.New("untrusted issuer")
+
+	// ErrTokenExpired indicates that the token's "exp" time has passed.
+	ErrTokenExpired = errors.New("token is expired")
+
+	// ErrTokenNotYetValid indicates that the token's "nbf" or "iat" time has
+	// not yet arrived.
+	ErrTokenNotYetValid = errors.New("token is not valid yet")
+
+	// ErrTokenAudience indicates that the token's "aud" claim does not
+	// contain any of the expected audiences.
+	ErrTokenAudience = errors.New("audience mismatch")
+)
+
+type (
+	// TokenError describes why the middleware rejected a request's token. It
+	// is a goa error that responds with the status of its class (e.g.
+	// ErrInvalidToken), and it wraps one of the ErrTokenXxx reasons. Use
+	// errors.As() to obtain either the TokenError or its *goa.ErrorResponse.
+	//
+	// TokenError never includes the token itself, so it is safe to return to
+	// clients and to log.
+	TokenError struct {
+		*goa.ErrorResponse
+		// Reason is one of the ErrTokenXxx errors.
+		Reason error `json:"-"`
+	}
+)
+
+// Unwrap returns the reason for the error.
+func (e *TokenError) Unwrap() error {
+	return e.Reason
+}
+
+// As sets target to the error's goa ErrorResponse if target is a
+// **goa.ErrorResponse, so that errors.As() treats a TokenError like any other
+// goa error.
+func (e *TokenError) As(target interface{}) bool {
+	if gerr, ok := target.(**goa.ErrorResponse); ok {
+		*gerr = e.ErrorResponse
+		return true
+	}
+	return false
+}
+
+// newTokenError creates a TokenError of the given class. Its detail message is
+// the reason's message; keyvals become its metadata.
+func newTokenError(class goa.ErrorClass, reason error, keyvals ...interface{}) error {
+	err := class(reason.Error(), keyvals...)
+	if gerr, ok := err.(*goa.ErrorResponse); ok {
+		return &TokenError{ErrorResponse: gerr, Reason: reason}
+	}
+	return err
+}
+
+// errorResponse returns the goa ErrorResponse that underlies err, or nil if
+// err is not a goa error.
+func errorResponse(err error) *goa.ErrorResponse {
+	switch te := err.(type) {
+	case *goa.ErrorResponse:
+		return te
+	case *TokenError:
+		return te.ErrorResponse
+	default:
+		return nil
+	}
+}
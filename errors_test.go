@@ -0,0 +1,117 @@
+package jwtauth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"golang.org/x/net/context"
+
+	jwtpkg "github.com/dgrijalva/jwt-go"
+	"github.com/goadesign/goa"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rightscale/goa-jwtauth"
+)
+
+var _ = Describe("TokenError", func() {
+	var resp *httptest.ResponseRecorder
+	var req *http.Request
+	var stack goa.Handler
+	var middleware goa.Middleware
+
+	BeforeEach(func() {
+		resp = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "http://example.com/", nil)
+		stack = func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			return nil
+		}
+		store := &jwtauth.NamedKeystore{}
+		Ω(store.Trust("alice", hmacKey1)).ShouldNot(HaveOccurred())
+		middleware = jwtauth.New(commonScheme, store, jwtauth.Required(), jwtauth.Audience("cellar"))
+	})
+
+	reject := func(token string) error {
+		if token != "" {
+			setBearerHeader(req, token)
+		}
+		return middleware(stack)(context.Background(), resp, req)
+	}
+
+	withAudience := func(keyvals ...interface{}) string {
+		token, err := jwtauth.NewToken(hmacKey1, jwtauth.NewClaims(append(keyvals, "aud", "cellar")...))
+		Ω(err).ShouldNot(HaveOccurred())
+		return token
+	}
+
+	now := time.Now()
+
+	expectReason := func(err error, reason error) {
+		Ω(errors.Is(err, reason)).Should(BeTrue())
+		Ω(err).Should(HaveResponseStatus(401))
+
+		var te *jwtauth.TokenError
+		Ω(errors.As(err, &te)).Should(BeTrue())
+		Ω(te.Reason).Should(Equal(reason))
+	}
+
+	It("reports missing tokens", func() {
+		expectReason(reject(""), jwtauth.ErrTokenMissing)
+	})
+
+	It("reports malformed tokens", func() {
+		expectReason(reject("not.a.jwt"), jwtauth.ErrTokenMalformed)
+	})
+
+	It("reports bad signatures", func() {
+		expectReason(reject(withAudience("iss", "alice")+"x"), jwtauth.ErrTokenSignature)
+	})
+
+	It("reports disallowed algorithms", func() {
+		token := jwtpkg.NewWithClaims(jwtpkg.SigningMethodNone, jwtpkg.MapClaims{"iss": "alice"})
+		s, err := token.SignedString(jwtpkg.UnsafeAllowNoneSignatureType)
+		Ω(err).ShouldNot(HaveOccurred())
+		expectReason(reject(s), jwtauth.ErrTokenAlgorithm)
+	})
+
+	It("reports untrusted issuers", func() {
+		expectReason(reject(withAudience("iss", "mallory")), jwtauth.ErrTokenUntrusted)
+	})
+
+	It("reports expired tokens", func() {
+		expectReason(reject(withAudience("iss", "alice", "exp", now.Add(-time.Minute).Unix())), jwtauth.ErrTokenExpired)
+	})
+
+	It("reports not-yet-valid tokens", func() {
+		expectReason(reject(withAudience("iss", "alice", "nbf", now.Add(time.Minute).Unix())), jwtauth.ErrTokenNotYetValid)
+	})
+
+	It("reports audience mismatches", func() {
+		expectReason(reject(makeToken("alice", "bob", hmacKey1)), jwtauth.ErrTokenAudience)
+	})
+
+	It("can be treated as a goa error", func() {
+		err := reject(withAudience("iss", "alice") + "x")
+
+		var gerr *goa.ErrorResponse
+		Ω(errors.As(err, &gerr)).Should(BeTrue())
+		Ω(gerr.Code).Should(Equal("invalid_token"))
+		Ω(gerr.Status).Should(Equal(401))
+	})
+
+	It("classifies trust and validity failures as ErrAuthenticationFailed", func() {
+		err := reject(withAudience("iss", "mallory"))
+
+		gerr := err.(*jwtauth.TokenError).ErrorResponse
+		Ω(gerr.Code).Should(Equal("authentication_failed"))
+	})
+
+	It("does not reveal the token", func() {
+		token := withAudience("iss", "alice", "exp", now.Add(-time.Minute).Unix())
+		err := reject(token)
+
+		Ω(err.Error()).ShouldNot(ContainSubstring(token))
+		Ω(err.(*jwtauth.TokenError).Meta).ShouldNot(ContainElement(token))
+	})
+})
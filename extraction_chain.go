@@ -0,0 +1,100 @@
+package jwtauth
+
+import (
+	"net/http"
+
+	"github.com/goadesign/goa"
+)
+
+type (
+	// TokenSource is one place where a request may carry a JWT. Create sources
+	// with FromHeader(), FromCookie(), FromQuery() and FromForm(), then combine
+	// them with ExtractionChain().
+	TokenSource struct {
+		// Name identifies the source, e.g. "header:Authorization". It is
+		// reported by ContextTokenSource() when the source provides a token.
+		Name string
+
+		extract func(*http.Request) string
+	}
+)
+
+// FromHeader creates a TokenSource that finds the JWT in the named header. The
+// header value must begin with one of the given prefixes (compared without
+// regard to case), followed by a space and the token:
+//
+//     FromHeader("Authorization", "Bearer", "JWT")
+//
+// A header that uses any other prefix, such as "Basic", is ignored. If no
+// prefixes are given, the header value must be a bare token.
+func FromHeader(name string, prefixes ...string) TokenSource {
+	return TokenSource{
+		Name: sourceName(goa.LocHeader, name),
+		extract: func(req *http.Request) string {
+			return extractHeader(req, name, len(prefixes) == 0, prefixes)
+		},
+	}
+}
+
+// FromCookie creates a TokenSource that finds the JWT in the named cookie.
+func FromCookie(name string) TokenSource {
+	return TokenSource{
+		Name:    sourceName(LocCookie, name),
+		extract: func(req *http.Request) string { return extractCookie(req, name) },
+	}
+}
+
+// FromQuery creates a TokenSource that finds the JWT in the named query-string
+// parameter.
+func FromQuery(name string) TokenSource {
+	return TokenSource{
+		Name:    sourceName(goa.LocQuery, name),
+		extract: func(req *http.Request) string { return extractQuery(req, name) },
+	}
+}
+
+// FromForm creates a TokenSource that finds the JWT in the named parameter of
+// a form-encoded request body. Like DefaultExtraction with LocForm, it leaves
+// the request body intact, but finds no JWT in requests for goa actions that
+// have a payload.
+func FromForm(name string) TokenSource {
+	return TokenSource{
+		Name:    sourceName(LocForm, name),
+		extract: func(req *http.Request) string { return extractForm(req, name) },
+	}
+}
+
+// ExtractionChain creates an ExtractionFunc that looks for the JWT in each of
+// the given sources, in order, and ignores the middleware's security scheme.
+// For example, to accept a bearer token, or a session cookie for browsers:
+//
+//     jwtauth.Extraction(jwtauth.ExtractionChain(
+//         jwtauth.FromHeader("Authorization", "Bearer"),
+//         jwtauth.FromCookie("session"),
+//     ))
+//
+// A request that carries tokens in more than one of the sources is ambiguous;
+// per RFC 6750 Section 2, it is rejected with ErrInvalidRequest.
+//
+// The name of the source that provided the token is available to later
+// handlers via ContextTokenSource().
+func ExtractionChain(sources ...TokenSource) ExtractionFunc {
+	return func(scheme *goa.JWTSecurity, req *http.Request) (string, error) {
+		var tok, source string
+		for _, s := range sources {
+			t := s.extract(req)
+			if t == "" {
+				continue
+			}
+			if tok != "" {
+				return "", newTokenError(ErrInvalidRequest, ErrTokenAmbiguous, "sources", []string{source, s.Name})
+			}
+			tok, source = t, s.Name
+		}
+
+		if tok != "" {
+			recordSource(req, source)
+		}
+		return tok, nil
+	}
+}
@@ -0,0 +1,155 @@
+package jwtauth_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/goadesign/goa"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rightscale/goa-jwtauth"
+)
+
+var _ = Describe("ExtractionChain()", func() {
+	var req *http.Request
+
+	chain := jwtauth.ExtractionChain(
+		jwtauth.FromHeader("Authorization", "Bearer", "JWT"),
+		jwtauth.FromCookie("jwt"),
+		jwtauth.FromQuery("access_token"),
+	)
+
+	BeforeEach(func() {
+		req, _ = http.NewRequest("GET", "http://example.com/", nil)
+	})
+
+	extract := func() string {
+		tok, err := chain(commonScheme, req)
+		Ω(err).ShouldNot(HaveOccurred())
+		return tok
+	}
+
+	It("extracts from headers with an expected prefix", func() {
+		req.Header.Set("Authorization", "Bearer header-token")
+		Ω(extract()).Should(Equal("header-token"))
+
+		req.Header.Set("Authorization", "jwt header-token")
+		Ω(extract()).Should(Equal("header-token"))
+	})
+
+	It("ignores headers with other prefixes", func() {
+		req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+		Ω(extract()).Should(Equal(""))
+
+		req.Header.Set("Authorization", "header-token")
+		Ω(extract()).Should(Equal(""))
+	})
+
+	It("extracts bare tokens from headers without prefixes", func() {
+		bare := jwtauth.ExtractionChain(jwtauth.FromHeader("X-Token"))
+
+		req.Header.Set("X-Token", "header-token")
+		Ω(bare(commonScheme, req)).Should(Equal("header-token"))
+
+		req.Header.Set("X-Token", "Bearer header-token")
+		Ω(bare(commonScheme, req)).Should(Equal(""))
+	})
+
+	It("extracts from later sources", func() {
+		req.AddCookie(&http.Cookie{Name: "jwt", Value: "cookie-token"})
+		Ω(extract()).Should(Equal("cookie-token"))
+
+		req, _ = http.NewRequest("GET", "http://example.com/?access_token=query-token", nil)
+		Ω(extract()).Should(Equal("query-token"))
+	})
+
+	It("rejects tokens in several sources", func() {
+		req, _ = http.NewRequest("GET", "http://example.com/?access_token=query-token", nil)
+		req.Header.Set("Authorization", "Bearer header-token")
+
+		_, err := chain(commonScheme, req)
+		Ω(err).Should(HaveResponseStatus(400))
+		Ω(errors.Is(err, jwtauth.ErrTokenAmbiguous)).Should(BeTrue())
+		Ω(err.Error()).ShouldNot(ContainSubstring("query-token"))
+	})
+
+	Context("in a middleware", func() {
+		var resp *httptest.ResponseRecorder
+		var source string
+		var stack goa.Handler
+
+		BeforeEach(func() {
+			resp = httptest.NewRecorder()
+			source = "unset"
+			stack = func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				source = jwtauth.ContextTokenSource(ctx)
+				return nil
+			}
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1},
+				jwtauth.Extraction(chain))
+			stack = middleware(stack)
+		})
+
+		It("reports the source of the token", func() {
+			req.AddCookie(&http.Cookie{Name: "jwt", Value: makeToken("alice", "bob", hmacKey1)})
+
+			Ω(stack(context.Background(), resp, req)).Should(Succeed())
+			Ω(source).Should(Equal("cookie:jwt"))
+		})
+
+		It("reports no source without a token", func() {
+			Ω(stack(context.Background(), resp, req)).Should(Succeed())
+			Ω(source).Should(Equal(""))
+		})
+
+		It("challenges ambiguous requests", func() {
+			tok := makeToken("alice", "bob", hmacKey1)
+			setBearerHeader(req, tok)
+			req.AddCookie(&http.Cookie{Name: "jwt", Value: tok})
+
+			err := stack(context.Background(), resp, req)
+			Ω(err).Should(HaveResponseStatus(400))
+			Ω(resp.Header().Get("WWW-Authenticate")).Should(HavePrefix(`Bearer error="invalid_request"`))
+		})
+	})
+})
+
+var _ = Describe("DefaultExtraction() in a middleware", func() {
+	It("reports the source of the token", func() {
+		var source string
+		stack := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+			source = jwtauth.ContextTokenSource(ctx)
+			return nil
+		}
+		middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1})
+
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		setBearerHeader(req, makeToken("alice", "bob", hmacKey1))
+
+		Ω(middleware(stack)(context.Background(), httptest.NewRecorder(), req)).Should(Succeed())
+		Ω(source).Should(Equal("header:Authorization"))
+	})
+
+	It("leaves form-encoded bodies for the next handler", func() {
+		var body []byte
+		stack := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+			body, _ = ioutil.ReadAll(req.Body)
+			return nil
+		}
+		scheme := &goa.JWTSecurity{In: jwtauth.LocForm, Name: "access_token"}
+		middleware := jwtauth.New(scheme, &jwtauth.SimpleKeystore{hmacKey1}, jwtauth.Required())
+
+		form := url.Values{"access_token": {makeToken("alice", "bob", hmacKey1)}, "vintage": {"1982"}}.Encode()
+		req, _ := http.NewRequest("POST", "http://example.com/", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		Ω(middleware(stack)(context.Background(), httptest.NewRecorder(), req)).Should(Succeed())
+		Ω(string(body)).Should(Equal(form))
+	})
+})
@@ -0,0 +1,192 @@
+/*
+Package grpcauth provides gRPC server interceptors that verify JWTs exactly as
+the jwtauth middleware does for goa services. It lives in its own package so
+that applications that do not use gRPC need not depend on it.
+
+		scopes := map[string][]string{"/bottles.Cellar/Drink": {"drink"}}
+		server := grpc.NewServer(
+			grpc.UnaryInterceptor(grpcauth.NewUnaryInterceptor(store, scopes, grpcauth.DenyUnmapped())),
+			grpc.StreamInterceptor(grpcauth.NewStreamInterceptor(store, scopes, grpcauth.DenyUnmapped())),
+		)
+
+By default, methods that are not in the scopes map require no scopes, so any
+caller with a valid token may call them. Use the DenyUnmapped() option to
+reject calls to such methods instead.
+*/
+package grpcauth
+
+import (
+	"errors"
+	"net/http"
+	"net/textproto"
+	"net/url"
+
+	"github.com/goadesign/goa"
+	"github.com/rightscale/goa-jwtauth"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type (
+	// config is a state accumulator for Option.
+	config struct {
+		DenyUnmapped bool
+		Options      []jwtauth.Option
+	}
+
+	// Option is a function that applies options to an interceptor. Call With()
+	// and DenyUnmapped() to instantiate suitable options.
+	Option func(*config)
+)
+
+// scheme is the security scheme of gRPC interceptors: by convention, gRPC
+// clients send tokens in the "authorization" metadata key.
+var scheme = &goa.JWTSecurity{In: goa.LocHeader, Name: "Authorization"}
+
+// With applies jwtauth options, such as jwtauth.Required() or
+// jwtauth.Authorization(), to an interceptor.
+//
+// The default behavior is the default behavior of a jwtauth middleware.
+func With(options ...jwtauth.Option) Option {
+	return func(c *config) {
+		c.Options = append(c.Options, options...)
+	}
+}
+
+// DenyUnmapped causes an interceptor to reject calls to methods that are not
+// in its scopes map with PermissionDenied, whether or not they carry a token.
+//
+// The default behavior is to require no scopes for methods that are not in
+// the scopes map.
+func DenyUnmapped() Option {
+	return func(c *config) {
+		c.DenyUnmapped = true
+	}
+}
+
+// NewUnaryInterceptor creates a gRPC unary server interceptor that verifies
+// JWTs using the specified keystore and options. The token is read from the
+// "authorization" key of the incoming metadata, optionally prefixed by
+// "Bearer".
+//
+// The scopes map full method names, such as "/bottles.Cellar/Drink", to the
+// scopes that each method requires; they are checked by the authorization
+// function as if they were goa.ContextRequiredScopes(). Methods that are not
+// in the map require no scopes, unless the DenyUnmapped() option is given.
+//
+// The claims of a valid token are available to the handler via
+// jwtauth.ContextClaims(). Rejected calls fail with a gRPC status whose code
+// corresponds to the jwtauth error class: Unauthenticated for
+// jwtauth.ErrInvalidToken and jwtauth.ErrAuthenticationFailed,
+// PermissionDenied for jwtauth.ErrAuthorizationFailed, InvalidArgument for
+// jwtauth.ErrInvalidRequest, and Internal otherwise.
+func NewUnaryInterceptor(store jwtauth.Keystore, scopes map[string][]string, options ...Option) grpc.UnaryServerInterceptor {
+	auth := newAuthenticator(store, scopes, options)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := auth(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// NewStreamInterceptor creates a gRPC stream server interceptor that verifies
+// JWTs when a stream is opened. It behaves like NewUnaryInterceptor(); the
+// claims of a valid token are available via
+// jwtauth.ContextClaims(stream.Context()).
+func NewStreamInterceptor(store jwtauth.Keystore, scopes map[string][]string, options ...Option) grpc.StreamServerInterceptor {
+	auth := newAuthenticator(store, scopes, options)
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := auth(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream is a grpc.ServerStream whose context contains the claims.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context of the stream.
+func (ss *serverStream) Context() context.Context {
+	return ss.ctx
+}
+
+// newAuthenticator creates a function that verifies the JWT that accompanies a
+// gRPC call and authorizes the call against the scopes required by its method.
+func newAuthenticator(store jwtauth.Keystore, scopes map[string][]string, options []Option) func(context.Context, string) (context.Context, error) {
+	c := &config{}
+	for _, o := range options {
+		o(c)
+	}
+	auth := jwtauth.NewAuthenticator(scheme, store, c.Options...)
+
+	return func(ctx context.Context, method string) (context.Context, error) {
+		reqd, ok := scopes[method]
+		if !ok && c.DenyUnmapped {
+			return ctx, status.Error(codes.PermissionDenied, "method is not mapped to scopes")
+		}
+		if ok {
+			ctx = goa.WithRequiredScopes(ctx, reqd)
+		}
+
+		ctx, err := auth(ctx, rpcRequest(ctx, method))
+		if err != nil {
+			return ctx, rpcError(err)
+		}
+		return ctx, nil
+	}
+}
+
+// rpcRequest adapts a gRPC call to an HTTP request so that ExtractionFuncs
+// can find its token. The call's metadata become the request's headers.
+func rpcRequest(ctx context.Context, method string) *http.Request {
+	header := http.Header{}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for k, vs := range md {
+			for _, v := range vs {
+				header.Add(textproto.CanonicalMIMEHeaderKey(k), v)
+			}
+		}
+	}
+
+	req := &http.Request{
+		Method:     "POST",
+		URL:        &url.URL{Path: method},
+		Proto:      "HTTP/2.0",
+		ProtoMajor: 2,
+		Header:     header,
+	}
+	return req.WithContext(ctx)
+}
+
+// rpcError converts a jwtauth error into a gRPC status error.
+func rpcError(err error) error {
+	var gerr *goa.ErrorResponse
+	if !errors.As(err, &gerr) {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	var code codes.Code
+	switch gerr.Status {
+	case 400:
+		code = codes.InvalidArgument
+	case 401:
+		code = codes.Unauthenticated
+	case 403:
+		code = codes.PermissionDenied
+	default:
+		code = codes.Internal
+	}
+	return status.Error(code, gerr.Detail)
+}
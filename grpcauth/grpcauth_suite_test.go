@@ -0,0 +1,37 @@
+package grpcauth_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	jwtauth "github.com/rightscale/goa-jwtauth"
+
+	"testing"
+)
+
+func TestGRPCAuthSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "goa-jwtauth/grpcauth")
+}
+
+var hmacKey1 = []byte("I like tacos")
+
+var hmacKey2 = []byte("I hate oysters")
+
+func makeToken(issuer, subject string, key interface{}, scopes ...string) string {
+	now := time.Now()
+	claims := jwtauth.NewClaims(
+		"iss", issuer,
+		"sub", subject,
+		"iat", now.Unix(),
+		"exp", now.Add(time.Minute).Unix(),
+		"scopes", scopes,
+	)
+
+	token, err := jwtauth.NewToken(key, claims)
+	if err != nil {
+		panic(err)
+	}
+	return token
+}
@@ -0,0 +1,121 @@
+package grpcauth_test
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rightscale/goa-jwtauth"
+	"github.com/rightscale/goa-jwtauth/grpcauth"
+)
+
+// testStream is a grpc.ServerStream that has only a context.
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ts *testStream) Context() context.Context {
+	return ts.ctx
+}
+
+var _ = Describe("gRPC interceptors", func() {
+	const method = "/bottles.Cellar/Drink"
+	scopes := map[string][]string{method: {"drink"}}
+	store := &jwtauth.SimpleKeystore{Key: hmacKey1}
+
+	incoming := func(token string) context.Context {
+		md := metadata.Pairs("authorization", "Bearer "+token)
+		return metadata.NewIncomingContext(context.Background(), md)
+	}
+
+	Context("NewUnaryInterceptor()", func() {
+		interceptor := grpcauth.NewUnaryInterceptor(store, scopes)
+		var claims jwtauth.Claims
+
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			claims = jwtauth.ContextClaims(ctx)
+			return "ok", nil
+		}
+
+		call := func(ctx context.Context, method string) (interface{}, error) {
+			claims = nil
+			return interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		}
+
+		It("adds claims to the context", func() {
+			resp, err := call(incoming(makeToken("alice", "bob", hmacKey1, "drink")), method)
+
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(resp).Should(Equal("ok"))
+			Ω(claims.Subject()).Should(Equal("bob"))
+		})
+
+		It("rejects invalid tokens", func() {
+			_, err := call(incoming(makeToken("alice", "bob", hmacKey2, "drink")), method)
+
+			Ω(status.Code(err)).Should(Equal(codes.Unauthenticated))
+			Ω(claims).Should(BeNil())
+		})
+
+		It("rejects calls that lack required scopes", func() {
+			_, err := call(incoming(makeToken("alice", "bob", hmacKey1, "read")), method)
+
+			Ω(status.Code(err)).Should(Equal(codes.PermissionDenied))
+		})
+
+		It("requires no scopes for other methods", func() {
+			_, err := call(incoming(makeToken("alice", "bob", hmacKey1)), "/bottles.Cellar/Show")
+
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("honors options", func() {
+			interceptor := grpcauth.NewUnaryInterceptor(store, nil, grpcauth.With(jwtauth.Required()))
+			_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+
+			Ω(status.Code(err)).Should(Equal(codes.Unauthenticated))
+		})
+
+		It("denies unmapped methods given DenyUnmapped()", func() {
+			interceptor := grpcauth.NewUnaryInterceptor(store, scopes, grpcauth.DenyUnmapped())
+			info := &grpc.UnaryServerInfo{FullMethod: "/bottles.Cellar/Show"}
+			_, err := interceptor(incoming(makeToken("alice", "bob", hmacKey1, "drink")), nil, info, handler)
+
+			Ω(status.Code(err)).Should(Equal(codes.PermissionDenied))
+
+			info.FullMethod = method
+			_, err = interceptor(incoming(makeToken("alice", "bob", hmacKey1, "drink")), nil, info, handler)
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Context("NewStreamInterceptor()", func() {
+		interceptor := grpcauth.NewStreamInterceptor(store, scopes)
+		var claims jwtauth.Claims
+
+		handler := func(srv interface{}, ss grpc.ServerStream) error {
+			claims = jwtauth.ContextClaims(ss.Context())
+			return nil
+		}
+
+		It("adds claims to the stream's context", func() {
+			ss := &testStream{ctx: incoming(makeToken("alice", "bob", hmacKey1, "drink"))}
+			err := interceptor(nil, ss, &grpc.StreamServerInfo{FullMethod: method}, handler)
+
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(claims.Subject()).Should(Equal("bob"))
+		})
+
+		It("rejects calls that lack required scopes", func() {
+			ss := &testStream{ctx: incoming(makeToken("alice", "bob", hmacKey1))}
+			err := interceptor(nil, ss, &grpc.StreamServerInfo{FullMethod: method}, handler)
+
+			Ω(status.Code(err)).Should(Equal(codes.PermissionDenied))
+		})
+	})
+})
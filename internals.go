@@ -2,54 +2,356 @@ package jwtauth
 
 import (
 	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/goadesign/goa"
+	"golang.org/x/net/context"
 )
 
-// parseToken does the gruntwork of extracting A JWT from a request.
-func parseToken(scheme *goa.JWTSecurity, store Keystore, exfn ExtractionFunc, req *http.Request) (*jwt.Token, error) {
-	tok, err1 := exfn(scheme, req)
-	if err1 != nil {
-		return nil, err1
+// maxKeyAttempts bounds the number of keys that parseToken will try when a
+// token has no Key ID and its issuer has several keys; the issuer's oldest
+// keys are the ones left untried.
+const maxKeyAttempts = 8
+
+// authenticate extracts and verifies the request's JWT, then authorizes the
+// request. It returns a context that contains the token's claims and
+// principal.
+func authenticate(ctx context.Context, oo *mwopts, req *http.Request) (context.Context, error) {
+	ctx = withOptions(ctx, oo)
+	ctx = withRequest(ctx, req)
+
+	ctx, tok, err := extractToken(ctx, oo, req)
+	if err != nil {
+		return ctx, err
+	}
+	token, err := parseToken(ctx, oo, tok)
+	if err != nil {
+		return ctx, err
 	}
 
-	var alg string
-	var key interface{}
-	parsed, err := jwt.Parse(tok, func(token *jwt.Token) (interface{}, error) {
+	claims := Claims{}
+	if token != nil {
+		switch tc := token.Claims.(type) {
+		case jwt.MapClaims:
+			claims = Claims(tc)
+		default:
+			// this is impossible; jwt only produces MapClaims when parsing
+			panic(fmt.Sprintf("unsupported jwt.Claims type %T", tc))
+		}
+	}
+
+	ctx = WithClaims(ctx, claims)
+
+	if token != nil && oo.Principal != nil {
+		principal, err := oo.Principal(ctx, claims)
+		if err != nil {
+			return ctx, err
+		}
+		ctx = WithPrincipal(ctx, principal)
+	}
+
+	if oo.Authorization != nil {
+		err = oo.Authorization(ctx, claims)
+	}
+	if err == nil && oo.RequestAuthorization != nil {
+		err = oo.RequestAuthorization(ctx, req, claims)
+	}
+	return ctx, err
+}
+
+// extractToken calls the ExtractionFunc to find the request's JWT. If the
+// ExtractionFunc reports the token's source, extractToken records it in the
+// returned context.
+func extractToken(ctx context.Context, oo *mwopts, req *http.Request) (context.Context, string, error) {
+	var source string
+	r := req.WithContext(context.WithValue(req.Context(), sourceRecorderKey, &source))
+
+	tok, err := oo.Extraction(oo.Scheme, r)
+	// keep the body that the ExtractionFunc may have replaced after reading
+	// it, and any form that it parsed
+	req.Body, req.Form, req.PostForm = r.Body, r.Form, r.PostForm
+	if err == nil && tok != "" && source != "" {
+		ctx = withTokenSource(ctx, source)
+	}
+	return ctx, tok, err
+}
+
+// recordSource reports the source from which an ExtractionFunc extracted the
+// request's JWT, if the request came from extractToken.
+func recordSource(req *http.Request, source string) {
+	if recorder, ok := req.Context().Value(sourceRecorderKey).(*string); ok {
+		*recorder = source
+	}
+}
+
+// parseToken does the gruntwork of verifying a JWT.
+func parseToken(ctx context.Context, oo *mwopts, tok string) (*jwt.Token, error) {
+	if tok == "" {
+		if oo.Required {
+			return nil, newTokenError(ErrInvalidToken, ErrTokenMissing)
+		}
+		return nil, nil
+	}
+
+	// time-based claims are validated below, with leeway
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+
+	var alg, iss string
+	var keys []interface{}
+	parsed, err := parser.Parse(tok, func(token *jwt.Token) (interface{}, error) {
+		var err error
 		alg, _ = token.Header["alg"].(string)
-		iss, err := identifyIssuer(token)
+		iss, err = identifyIssuer(token)
 		if err != nil {
 			return nil, err
 		}
-		key = store.Get(iss)
-		if key == nil {
-			return nil, ErrInvalidToken("untrusted", "issuer", iss)
+		if !algorithmAllowed(oo, iss, alg) {
+			return nil, newTokenError(ErrInvalidToken, ErrTokenAlgorithm, "issuer", iss, "alg", alg)
+		}
+		kid, _ := token.Header["kid"].(string)
+		keys = lookupKeys(oo.Keystore, iss, kid)
+		if len(keys) == 0 {
+			if kid != "" {
+				return nil, newTokenError(ErrAuthenticationFailed, ErrTokenUntrusted, "issuer", iss, "kid", kid)
+			}
+			return nil, newTokenError(ErrAuthenticationFailed, ErrTokenUntrusted, "issuer", iss)
 		}
-		return key, nil
+		keys = keysForAlgorithm(keys, alg)
+		if len(keys) == 0 {
+			return nil, newTokenError(ErrInvalidToken, ErrTokenAlgorithm, "issuer", iss, "alg", alg)
+		}
+		return keys[0], nil
 	})
 
-	// help clients with mystery errors caused by fast-and-loose key
-	// typing in crypto and dgrijalva/jwt-go
-	if err != nil && strings.HasPrefix(err.Error(), "key is of invalid type") {
-		err = fmt.Errorf("%s (%T for algorithm %s)", err.Error(), key, alg)
-		panic(err)
+	// if the issuer has several keys, try the rest until one verifies the
+	// signature; prefer to report a bad signature over a mistyped key
+	var key interface{}
+	if len(keys) > 0 {
+		key = keys[0]
+	}
+	for i := 1; i < len(keys) && isSignatureError(err); i++ {
+		candidate := keys[i]
+		p, e := parser.Parse(tok, func(*jwt.Token) (interface{}, error) {
+			return candidate, nil
+		})
+		if isKeyTypeError(e) && !isKeyTypeError(err) {
+			continue
+		}
+		parsed, err, key = p, e, candidate
 	}
 
-	if ve, ok := err.(*jwt.ValidationError); ok {
-		err = ve.Inner
+	// the keystore holds a key that crypto and dgrijalva/jwt-go cannot use
+	// with this algorithm (e.g. a private key or a non-pointer public key);
+	// this is a configuration problem rather than a bad token
+	if isKeyTypeError(err) {
+		err = ErrUnsupported("key is of invalid type", "issuer", iss, "alg", alg, "type", fmt.Sprintf("%T", key))
+		if oo.Alert != nil {
+			oo.Alert(ctx, err)
+		}
+		return nil, err
 	}
+
 	if err != nil {
-		err = ErrInvalidToken(err.Error(), "token", tok)
+		return nil, classifyError(err, iss)
+	}
+
+	if claims, ok := parsed.Claims.(jwt.MapClaims); ok {
+		err = validateTimes(Claims(claims), oo.Clock(), oo.Leeway)
+		if err == nil && oo.Audiences != nil {
+			err = validateAudience(Claims(claims), oo.Audiences)
+		}
 	}
 
 	return parsed, err
 }
 
+// classifyError transforms an error returned by jwt-go into a TokenError, or
+// returns it unchanged if it is already a jwtauth or goa error.
+func classifyError(err error, issuer string) error {
+	ve, ok := err.(*jwt.ValidationError)
+	if !ok {
+		return newTokenError(ErrInvalidToken, ErrTokenMalformed)
+	}
+
+	switch ve.Inner.(type) {
+	case *TokenError, *goa.ErrorResponse:
+		return ve.Inner
+	}
+
+	switch {
+	case ve.Errors&jwt.ValidationErrorMalformed != 0:
+		return newTokenError(ErrInvalidToken, ErrTokenMalformed)
+	case ve.Errors&jwt.ValidationErrorUnverifiable != 0:
+		return newTokenError(ErrInvalidToken, ErrTokenAlgorithm)
+	case ve.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		return newTokenError(ErrInvalidToken, ErrTokenSignature, "issuer", issuer)
+	default:
+		return newTokenError(ErrInvalidToken, ErrTokenMalformed)
+	}
+}
+
+// validateTimes checks the "exp", "nbf" and "iat" claims, if present, against
+// the current time, allowing for the specified leeway.
+func validateTimes(claims Claims, now time.Time, leeway time.Duration) error {
+	if _, ok := claims["exp"]; ok && now.After(claims.ExpiresAt().Add(leeway)) {
+		return newTokenError(ErrAuthenticationFailed, ErrTokenExpired, "issuer", claims.Issuer())
+	}
+	if _, ok := claims["iat"]; ok && now.Add(leeway).Before(claims.IssuedAt()) {
+		return newTokenError(ErrAuthenticationFailed, ErrTokenNotYetValid, "issuer", claims.Issuer(), "claim", "iat")
+	}
+	if _, ok := claims["nbf"]; ok && now.Add(leeway).Before(claims.NotBefore()) {
+		return newTokenError(ErrAuthenticationFailed, ErrTokenNotYetValid, "issuer", claims.Issuer(), "claim", "nbf")
+	}
+	return nil
+}
+
+// validateAudience ensures that the "aud" claim contains at least one of the
+// expected audiences.
+func validateAudience(claims Claims, expected []string) error {
+	for _, aud := range claims.Strings("aud") {
+		for _, e := range expected {
+			if aud == e {
+				return nil
+			}
+		}
+	}
+	return newTokenError(ErrInvalidToken, ErrTokenAudience, "expected", expected)
+}
+
+// lookupKeys finds the keys that could be used to verify a token from the
+// given issuer. If the token has a Key ID and the keystore supports Key IDs,
+// the key must match both issuer and ID or, if the issuer has no key with
+// that ID, it must be the issuer's key without an ID, so that keys trusted
+// with Trust() keep working when issuers start to send Key IDs. Otherwise,
+// the issuer alone selects the keys, newest first. At most maxKeyAttempts keys
+// are returned.
+func lookupKeys(store Keystore, issuer, kid string) []interface{} {
+	if ms, ok := store.(MultiKeystore); ok {
+		if kid != "" {
+			if key := ms.GetKey(issuer, kid); key != nil {
+				return []interface{}{key}
+			}
+			if key := ms.GetKey(issuer, ""); key != nil {
+				return []interface{}{key}
+			}
+			return nil
+		}
+		// Try the most recently trusted keys first, so that keys added by a
+		// rotation are never crowded out by older ones.
+		all := ms.GetKeys(issuer)
+		var keys []interface{}
+		for i := len(all) - 1; i >= 0 && len(keys) < maxKeyAttempts; i-- {
+			keys = append(keys, all[i])
+		}
+		return keys
+	}
+
+	if key := store.Get(issuer); key != nil {
+		return []interface{}{key}
+	}
+	return nil
+}
+
+// algorithmAllowed determines whether tokens from the given issuer may use the
+// given signing algorithm. The algorithm must appear in both the global and
+// the issuer's allowlist, if any; an empty (but non-nil) allowlist allows
+// nothing. The "none" algorithm is never allowed.
+func algorithmAllowed(oo *mwopts, issuer, alg string) bool {
+	if alg == "" || alg == jwt.SigningMethodNone.Alg() {
+		return false
+	}
+
+	if oo.Algorithms != nil && !containsString(oo.Algorithms, alg) {
+		return false
+	}
+	if allowed, ok := oo.IssuerAlgs[issuer]; ok && !containsString(allowed, alg) {
+		return false
+	}
+	return true
+}
+
+// containsString determines whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// keysForAlgorithm filters out keys that belong to a different family than
+// alg, e.g. RSA public keys when alg is HS256. This prevents an attacker from
+// using a public key as an HMAC secret. Keys of an unknown type are kept.
+func keysForAlgorithm(keys []interface{}, alg string) []interface{} {
+	family := algorithmFamily(alg)
+
+	var suitable []interface{}
+	for _, key := range keys {
+		kf := keyFamily(key)
+		if kf == "" || kf == family {
+			suitable = append(suitable, key)
+		}
+	}
+	return suitable
+}
+
+// algorithmFamily returns the family of keys that a JWT signing algorithm
+// uses: "hmac", "rsa", "ecdsa" or "eddsa". It returns "" for unknown
+// algorithms.
+func algorithmFamily(alg string) string {
+	switch {
+	case alg == SigningMethodEdDSA.Alg():
+		return "eddsa"
+	case strings.HasPrefix(alg, "HS"):
+		return "hmac"
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"):
+		return "rsa"
+	case strings.HasPrefix(alg, "ES"):
+		return "ecdsa"
+	default:
+		return ""
+	}
+}
+
+// keyFamily returns the family of a key: "hmac", "rsa", "ecdsa" or "eddsa". It
+// returns "" for keys of an unknown type.
+func keyFamily(key interface{}) string {
+	switch key.(type) {
+	case []byte, string:
+		return "hmac"
+	case rsa.PrivateKey, *rsa.PrivateKey, rsa.PublicKey, *rsa.PublicKey:
+		return "rsa"
+	case ecdsa.PrivateKey, *ecdsa.PrivateKey, ecdsa.PublicKey, *ecdsa.PublicKey:
+		return "ecdsa"
+	case ed25519.PrivateKey, *ed25519.PrivateKey, ed25519.PublicKey, *ed25519.PublicKey:
+		return "eddsa"
+	default:
+		return ""
+	}
+}
+
+// isSignatureError determines whether err indicates that a token's signature
+// could not be verified with the key that was tried.
+func isSignatureError(err error) bool {
+	ve, ok := err.(*jwt.ValidationError)
+	return ok && ve.Errors&jwt.ValidationErrorSignatureInvalid != 0
+}
+
+// isKeyTypeError determines whether err indicates that the key that was tried
+// is not of a type that is suitable for the token's algorithm.
+func isKeyTypeError(err error) bool {
+	ve, ok := err.(*jwt.ValidationError)
+	return ok && ve.Inner == jwt.ErrInvalidKeyType
+}
+
 // identifyIssuer inspects a JWT's claims to determine its issuer.
 func identifyIssuer(token *jwt.Token) (string, error) {
 	switch claims := token.Claims.(type) {
@@ -77,14 +379,40 @@ func identifyIssuer(token *jwt.Token) (string, error) {
 }
 
 // key2method determines a JWT SigningMethod that is suitable for the given key.
+// For ECDSA keys, the method depends on the key's curve.
 func key2method(key interface{}) jwt.SigningMethod {
-	switch key.(type) {
+	switch kt := key.(type) {
 	case []byte, string:
 		return jwt.SigningMethodHS256
 	case rsa.PrivateKey, *rsa.PrivateKey, rsa.PublicKey, *rsa.PublicKey:
 		return jwt.SigningMethodRS256
-	case ecdsa.PrivateKey, *ecdsa.PrivateKey, ecdsa.PublicKey, *ecdsa.PublicKey:
+	case ecdsa.PrivateKey:
+		return curve2method(kt.Curve)
+	case *ecdsa.PrivateKey:
+		return curve2method(kt.Curve)
+	case ecdsa.PublicKey:
+		return curve2method(kt.Curve)
+	case *ecdsa.PublicKey:
+		return curve2method(kt.Curve)
+	case ed25519.PrivateKey, *ed25519.PrivateKey, ed25519.PublicKey, *ed25519.PublicKey:
+		return SigningMethodEdDSA
+	default:
+		return nil
+	}
+}
+
+// curve2method determines the ECDSA SigningMethod that uses the given curve.
+func curve2method(curve elliptic.Curve) jwt.SigningMethod {
+	if curve == nil {
+		return nil
+	}
+	switch curve.Params().BitSize {
+	case 256:
 		return jwt.SigningMethodES256
+	case 384:
+		return jwt.SigningMethodES384
+	case 521:
+		return jwt.SigningMethodES512
 	default:
 		return nil
 	}
@@ -0,0 +1,148 @@
+package jwtauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+type (
+	// jsonWebKey is the subset of RFC 7517 JWK members that jwtauth
+	// understands.
+	jsonWebKey struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		Alg string `json:"alg"`
+		Crv string `json:"crv"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+		K   string `json:"k"`
+	}
+
+	// jsonWebKeySet is an RFC 7517 JWK Set document.
+	jsonWebKeySet struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	// jwkEntry is a verification key decoded from a JWK Set, together with
+	// its Key ID (which may be empty).
+	jwkEntry struct {
+		Kid string
+		Key interface{}
+	}
+)
+
+// parseJWKS decodes a JWK Set document into a list of verification keys, in
+// the order in which they appear in the document. Keys that are meant for
+// encryption, or whose type or curve jwtauth does not support, are skipped.
+// Symmetric ("oct") keys are skipped too, unless symmetric is true.
+func parseJWKS(document []byte, symmetric bool) ([]jwkEntry, error) {
+	var set jsonWebKeySet
+	if err := json.Unmarshal(document, &set); err != nil {
+		return nil, fmt.Errorf("malformed JWK Set: %s", err)
+	}
+
+	var entries []jwkEntry
+	for i, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if jwk.Kty == "oct" && !symmetric {
+			continue
+		}
+		key, err := jwk.decode()
+		if err != nil {
+			return nil, fmt.Errorf("malformed JWK at index %d: %s", i, err)
+		}
+		if key != nil {
+			entries = append(entries, jwkEntry{Kid: jwk.Kid, Key: key})
+		}
+	}
+
+	return entries, nil
+}
+
+// decode transforms a JWK into a key of an algorithm-specific type. It returns
+// nil (and no error) if the key type or curve is unsupported.
+func (jwk *jsonWebKey) decode() (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		if e.BitLen() > 31 {
+			return nil, fmt.Errorf("RSA exponent is too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, nil
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", jwk.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("Ed25519 public key has wrong size")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		k, err := base64.RawURLEncoding.DecodeString(jwk.K)
+		if err != nil {
+			return nil, err
+		}
+		return k, nil
+	default:
+		return nil, nil
+	}
+}
+
+// decodeBigInt decodes a base64url-encoded, big-endian unsigned integer as
+// used in JWK parameters.
+func decodeBigInt(value string) (*big.Int, error) {
+	if value == "" {
+		return nil, fmt.Errorf("missing required parameter")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
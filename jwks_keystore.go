@@ -0,0 +1,346 @@
+package jwtauth
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultJWKSInterval is the refresh interval used when
+	// JWKSKeystore.Interval is zero.
+	defaultJWKSInterval = time.Hour
+
+	// defaultJWKSBackoff is the initial retry delay used when
+	// JWKSKeystore.Backoff is zero.
+	defaultJWKSBackoff = 5 * time.Second
+
+	// maxJWKSDocument limits the size of a key set that jwtauth will read.
+	maxJWKSDocument = 1 << 20
+)
+
+type (
+	// JWKSKeystore is a concurrency-safe Keystore that trusts the keys
+	// published in a JSON Web Key Set (RFC 7517) document. The document is
+	// loaded from a local file or an HTTP(S) URL and refreshed periodically
+	// in the background.
+	//
+	// Every key in the set is trusted for Issuer. If Issuer is empty, the keys
+	// are trusted regardless of the token's issuer. JWKSKeystore implements
+	// MultiKeystore, using each key's "kid" member as its Key ID.
+	//
+	// Keys of an unsupported type or curve are ignored. Symmetric ("oct") keys
+	// are trusted only if the set is loaded from a local file; a secret that is
+	// published at a URL is no secret, so JWKSKeystore ignores it.
+	//
+	// Configure the exported fields, then call Start to load the key set and
+	// begin refreshing it; call Stop to end the background refresh.
+	JWKSKeystore struct {
+		// Issuer is the issuer whose keys the set contains.
+		Issuer string
+		// URL is the location of the key set: an http:// or https:// URL,
+		// or the path of a local file (optionally prefixed with file://).
+		URL string
+		// Interval is the time between successful refreshes. Defaults to one
+		// hour.
+		Interval time.Duration
+		// Backoff is the delay before retrying a failed refresh. It doubles
+		// after every consecutive failure, up to Interval. Defaults to five
+		// seconds.
+		Backoff time.Duration
+		// Client is used to fetch HTTP(S) key sets. Defaults to
+		// http.DefaultClient.
+		Client *http.Client
+		// OnError, if non-nil, is called whenever a background refresh fails.
+		// The previously-loaded keys remain trusted until a refresh succeeds.
+		OnError func(error)
+
+		sync.RWMutex
+		keys           []jwkEntry
+		revokedIssuers map[string]bool
+		revokedKeys    map[revokedKey]bool
+		stop           chan struct{}
+		done           chan struct{}
+	}
+
+	// revokedKey identifies a key that was revoked with RevokeKey.
+	revokedKey struct {
+		issuer, kid string
+	}
+)
+
+// Start loads the key set, then begins refreshing it in the background. It
+// returns an error (and does not start refreshing) if the initial load fails.
+func (jk *JWKSKeystore) Start() error {
+	if err := jk.Refresh(); err != nil {
+		return err
+	}
+
+	jk.Lock()
+	defer jk.Unlock()
+
+	if jk.stop == nil {
+		jk.stop = make(chan struct{})
+		jk.done = make(chan struct{})
+		go jk.run(jk.stop, jk.done)
+	}
+
+	return nil
+}
+
+// Stop ends the background refresh, if it is running, and waits for it to
+// finish. The most recently-loaded keys remain trusted.
+func (jk *JWKSKeystore) Stop() {
+	jk.Lock()
+	stop, done := jk.stop, jk.done
+	jk.stop, jk.done = nil, nil
+	jk.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// Refresh immediately reloads the key set. If the key set cannot be loaded,
+// it returns an error and the previously-loaded keys remain trusted.
+func (jk *JWKSKeystore) Refresh() error {
+	document, err := jk.fetch()
+	if err != nil {
+		return err
+	}
+
+	entries, err := parseJWKS(document, !jk.remote())
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("JWK Set at %s contains no usable keys", jk.URL)
+	}
+
+	jk.Lock()
+	defer jk.Unlock()
+	jk.keys = entries
+
+	return nil
+}
+
+// Trust cannot add keys, because JWKSKeystore's keys are managed by the key
+// set. It returns an error unless the key is already in the set; if it is,
+// Trust restores trust in an issuer that was revoked with RevokeTrust.
+func (jk *JWKSKeystore) Trust(issuer string, key interface{}) error {
+	if err := jk.trustKey(issuer, key, func(jwkEntry) bool { return true }); err != nil {
+		return err
+	}
+
+	jk.Lock()
+	defer jk.Unlock()
+	delete(jk.revokedIssuers, issuer)
+
+	return nil
+}
+
+// TrustKey cannot add keys, because JWKSKeystore's keys are managed by the key
+// set. It returns an error unless the key is already in the set with the given
+// Key ID; if it is, TrustKey restores trust in the issuer and in that key.
+func (jk *JWKSKeystore) TrustKey(issuer, kid string, key interface{}) error {
+	if err := jk.trustKey(issuer, key, func(e jwkEntry) bool { return e.Kid == kid }); err != nil {
+		return err
+	}
+
+	jk.Lock()
+	defer jk.Unlock()
+	delete(jk.revokedIssuers, issuer)
+	delete(jk.revokedKeys, revokedKey{issuer, kid})
+
+	return nil
+}
+
+// RevokeTrust revokes trust in all of an issuer's keys. The revocation outlasts
+// refreshes of the key set, until trust is restored with Trust or TrustKey.
+func (jk *JWKSKeystore) RevokeTrust(issuer string) {
+	jk.Lock()
+	defer jk.Unlock()
+
+	if jk.revokedIssuers == nil {
+		jk.revokedIssuers = map[string]bool{}
+	}
+	jk.revokedIssuers[issuer] = true
+}
+
+// RevokeKey revokes trust in the issuer's key that has the given Key ID. The
+// revocation outlasts refreshes of the key set, so the key remains untrusted
+// even if the set still publishes it, until trust is restored with TrustKey.
+func (jk *JWKSKeystore) RevokeKey(issuer, kid string) {
+	jk.Lock()
+	defer jk.Unlock()
+
+	if jk.revokedKeys == nil {
+		jk.revokedKeys = map[revokedKey]bool{}
+	}
+	jk.revokedKeys[revokedKey{issuer, kid}] = true
+}
+
+// Get returns the first trusted key in the set, or nil if the issuer is not
+// trusted or the key set has not been loaded.
+func (jk *JWKSKeystore) Get(issuer string) interface{} {
+	if entries := jk.entries(issuer); len(entries) > 0 {
+		return entries[0].Key
+	}
+
+	return nil
+}
+
+// GetKey returns the key in the set that has the given Key ID, or nil if the
+// issuer is not trusted or the set contains no such trusted key.
+func (jk *JWKSKeystore) GetKey(issuer, kid string) interface{} {
+	for _, e := range jk.entries(issuer) {
+		if e.Kid == kid {
+			return e.Key
+		}
+	}
+
+	return nil
+}
+
+// GetKeys returns every trusted key in the set, in the order in which they
+// appear in the set, or nil if the issuer is not trusted.
+func (jk *JWKSKeystore) GetKeys(issuer string) []interface{} {
+	entries := jk.entries(issuer)
+	if len(entries) == 0 {
+		return nil
+	}
+
+	keys := make([]interface{}, len(entries))
+	for i, e := range entries {
+		keys[i] = e.Key
+	}
+
+	return keys
+}
+
+// entries returns the keys in the set that are trusted for the issuer.
+func (jk *JWKSKeystore) entries(issuer string) []jwkEntry {
+	if !jk.trusts(issuer) {
+		return nil
+	}
+
+	jk.RLock()
+	defer jk.RUnlock()
+
+	if jk.revokedIssuers[issuer] {
+		return nil
+	}
+
+	var entries []jwkEntry
+	for _, e := range jk.keys {
+		if !jk.revokedKeys[revokedKey{issuer, e.Kid}] {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries
+}
+
+// trustKey implements Trust and TrustKey by searching the set for a matching
+// key.
+func (jk *JWKSKeystore) trustKey(issuer string, key interface{}, match func(jwkEntry) bool) error {
+	if !jk.trusts(issuer) {
+		return fmt.Errorf("cannot trust issuer '%s'; keystore only holds keys for '%s'", issuer, jk.Issuer)
+	}
+
+	jk.RLock()
+	defer jk.RUnlock()
+
+	for _, e := range jk.keys {
+		if match(e) && reflect.DeepEqual(e.Key, key) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("cannot trust additional keys; publish them in the key set instead")
+}
+
+// trusts determines whether the key set applies to the given issuer.
+func (jk *JWKSKeystore) trusts(issuer string) bool {
+	return jk.Issuer == "" || jk.Issuer == issuer
+}
+
+// run refreshes the key set until stop is closed, backing off exponentially
+// when refreshes fail.
+func (jk *JWKSKeystore) run(stop, done chan struct{}) {
+	defer close(done)
+
+	interval := jk.Interval
+	if interval <= 0 {
+		interval = defaultJWKSInterval
+	}
+	initialBackoff := jk.Backoff
+	if initialBackoff <= 0 {
+		initialBackoff = defaultJWKSBackoff
+	}
+	if initialBackoff > interval {
+		initialBackoff = interval
+	}
+
+	delay, backoff := interval, initialBackoff
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+
+		if err := jk.Refresh(); err != nil {
+			if jk.OnError != nil {
+				jk.OnError(err)
+			}
+			delay = backoff
+			backoff *= 2
+			if backoff > interval {
+				backoff = interval
+			}
+		} else {
+			delay, backoff = interval, initialBackoff
+		}
+
+		timer.Reset(delay)
+	}
+}
+
+// remote determines whether the key set is loaded from an HTTP(S) URL.
+func (jk *JWKSKeystore) remote() bool {
+	return strings.HasPrefix(jk.URL, "http://") || strings.HasPrefix(jk.URL, "https://")
+}
+
+// fetch reads the raw key set document from a file or URL.
+func (jk *JWKSKeystore) fetch() ([]byte, error) {
+	if !jk.remote() {
+		return ioutil.ReadFile(strings.TrimPrefix(jk.URL, "file://"))
+	}
+
+	client := jk.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Get(jk.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching JWK Set from %s: %s", jk.URL, resp.Status)
+	}
+
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxJWKSDocument))
+}
@@ -0,0 +1,287 @@
+package jwtauth_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	jwtauth "github.com/rightscale/goa-jwtauth"
+)
+
+// jwksDocument builds a JWK Set that contains the public half of every key.
+// Each key's "kid" is its zero-based position in the set.
+func jwksDocument(keys ...interface{}) []byte {
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+	var set []map[string]string
+	for i, key := range keys {
+		jwk := map[string]string{"kid": fmt.Sprintf("%d", i)}
+		switch tk := publicKey(key).(type) {
+		case []byte:
+			jwk["kty"] = "oct"
+			jwk["k"] = b64(tk)
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = b64(tk.N.Bytes())
+			jwk["e"] = b64(big.NewInt(int64(tk.E)).Bytes())
+		case *ecdsa.PublicKey:
+			jwk["kty"] = "EC"
+			jwk["crv"] = tk.Curve.Params().Name
+			jwk["x"] = b64(tk.X.Bytes())
+			jwk["y"] = b64(tk.Y.Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = b64(tk)
+		default:
+			panic(fmt.Sprintf("Unsupported key type for tests: %T", key))
+		}
+		set = append(set, jwk)
+	}
+
+	doc, err := json.Marshal(map[string]interface{}{"keys": set})
+	if err != nil {
+		panic(err)
+	}
+	return doc
+}
+
+var _ = Describe("JWKSKeystore", func() {
+	var mutex sync.Mutex
+	var document []byte
+	var status int
+	var server *httptest.Server
+	var store *jwtauth.JWKSKeystore
+
+	serve := func(doc []byte, code int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		document, status = doc, code
+	}
+
+	BeforeEach(func() {
+		serve(jwksDocument(rsaKey1, ecKey1, hmacKey1), http.StatusOK)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			w.WriteHeader(status)
+			w.Write(document)
+		}))
+		store = &jwtauth.JWKSKeystore{Issuer: "alice", URL: server.URL}
+	})
+
+	AfterEach(func() {
+		store.Stop()
+		server.Close()
+	})
+
+	Context("Start()", func() {
+		It("loads keys from a URL", func() {
+			Ω(store.Start()).ShouldNot(HaveOccurred())
+			Ω(store.Get("alice")).Should(Equal(&rsaKey1.PublicKey))
+		})
+
+		It("loads keys from a file", func() {
+			f, err := ioutil.TempFile("", "jwks")
+			Ω(err).ShouldNot(HaveOccurred())
+			defer os.Remove(f.Name())
+			f.Write(jwksDocument(ecKey2))
+			f.Close()
+
+			store.URL = f.Name()
+			Ω(store.Start()).ShouldNot(HaveOccurred())
+			Ω(store.Get("alice")).Should(Equal(&ecKey2.PublicKey))
+		})
+
+		It("skips keys of unsupported types and curves", func() {
+			serve([]byte(`{"keys":[
+				{"kty":"EC","crv":"P-192","x":"AQ","y":"AQ"},
+				{"kty":"XYZ"},
+				{"kty":"OKP","crv":"X25519","x":"AQ"},
+				`+string(jwksDocument(rsaKey1))[len(`{"keys":[`):]), http.StatusOK)
+			Ω(store.Start()).ShouldNot(HaveOccurred())
+			Ω(store.GetKeys("alice")).Should(Equal([]interface{}{&rsaKey1.PublicKey}))
+		})
+
+		It("ignores symmetric keys published at a URL", func() {
+			serve(jwksDocument(hmacKey1, rsaKey1), http.StatusOK)
+			Ω(store.Start()).ShouldNot(HaveOccurred())
+			Ω(store.GetKeys("alice")).Should(Equal([]interface{}{&rsaKey1.PublicKey}))
+
+			serve(jwksDocument(hmacKey1), http.StatusOK)
+			Ω(store.Refresh()).Should(HaveOccurred())
+		})
+
+		It("trusts symmetric keys from a file", func() {
+			f, err := ioutil.TempFile("", "jwks")
+			Ω(err).ShouldNot(HaveOccurred())
+			defer os.Remove(f.Name())
+			f.Write(jwksDocument(hmacKey1))
+			f.Close()
+
+			store.URL = f.Name()
+			Ω(store.Start()).ShouldNot(HaveOccurred())
+			Ω(store.Get("alice")).Should(Equal(hmacKey1))
+		})
+
+		It("loads Ed25519 keys", func() {
+			serve(jwksDocument(edKey1), http.StatusOK)
+			Ω(store.Start()).ShouldNot(HaveOccurred())
+			Ω(store.Get("alice")).Should(Equal(publicKey(edKey1)))
+		})
+
+		It("fails when the key set is unavailable", func() {
+			serve(nil, http.StatusNotFound)
+			Ω(store.Start()).Should(HaveOccurred())
+			Ω(store.Get("alice")).Should(BeNil())
+		})
+
+		It("fails when the key set is malformed", func() {
+			serve([]byte(`{"keys":[{"kty":"RSA","n":"!!"}]}`), http.StatusOK)
+			Ω(store.Start()).Should(HaveOccurred())
+		})
+
+		It("fails when the key set is empty", func() {
+			serve([]byte(`{"keys":[{"kty":"RSA","use":"enc"}]}`), http.StatusOK)
+			Ω(store.Start()).Should(HaveOccurred())
+		})
+	})
+
+	Context("background refresh", func() {
+		BeforeEach(func() {
+			store.Interval = 10 * time.Millisecond
+			store.Backoff = time.Millisecond
+		})
+
+		It("picks up new keys", func() {
+			Ω(store.Start()).ShouldNot(HaveOccurred())
+			serve(jwksDocument(rsaKey2), http.StatusOK)
+			Eventually(func() interface{} {
+				return store.Get("alice")
+			}).Should(Equal(&rsaKey2.PublicKey))
+		})
+
+		It("keeps old keys and reports errors on failure", func() {
+			var errMutex sync.Mutex
+			var failures int
+			store.OnError = func(error) {
+				errMutex.Lock()
+				defer errMutex.Unlock()
+				failures++
+			}
+
+			Ω(store.Start()).ShouldNot(HaveOccurred())
+			serve(nil, http.StatusInternalServerError)
+			Eventually(func() int {
+				errMutex.Lock()
+				defer errMutex.Unlock()
+				return failures
+			}).Should(BeNumerically(">", 1))
+			Ω(store.Get("alice")).Should(Equal(&rsaKey1.PublicKey))
+
+			serve(jwksDocument(ecKey1), http.StatusOK)
+			Eventually(func() interface{} {
+				return store.Get("alice")
+			}).Should(Equal(&ecKey1.PublicKey))
+		})
+	})
+
+	Context("Trust()", func() {
+		BeforeEach(func() {
+			Ω(store.Start()).ShouldNot(HaveOccurred())
+		})
+
+		It("accepts keys in the set", func() {
+			Ω(store.Trust("alice", &ecKey1.PublicKey)).ShouldNot(HaveOccurred())
+		})
+
+		It("rejects other keys and issuers", func() {
+			Ω(store.Trust("alice", &ecKey2.PublicKey)).Should(HaveOccurred())
+			Ω(store.Trust("bob", &ecKey1.PublicKey)).Should(HaveOccurred())
+		})
+	})
+
+	Context("RevokeTrust() and RevokeKey()", func() {
+		BeforeEach(func() {
+			Ω(store.Start()).ShouldNot(HaveOccurred())
+		})
+
+		It("revokes an issuer until it is trusted again", func() {
+			store.RevokeTrust("alice")
+			Ω(store.Get("alice")).Should(BeNil())
+			Ω(store.GetKeys("alice")).Should(BeEmpty())
+
+			Ω(store.Refresh()).ShouldNot(HaveOccurred())
+			Ω(store.Get("alice")).Should(BeNil())
+
+			Ω(store.Trust("alice", &rsaKey1.PublicKey)).ShouldNot(HaveOccurred())
+			Ω(store.Get("alice")).Should(Equal(&rsaKey1.PublicKey))
+		})
+
+		It("revokes a key until it is trusted again", func() {
+			store.RevokeKey("alice", "0")
+			Ω(store.GetKey("alice", "0")).Should(BeNil())
+			Ω(store.Get("alice")).Should(Equal(&ecKey1.PublicKey))
+
+			Ω(store.Refresh()).ShouldNot(HaveOccurred())
+			Ω(store.GetKey("alice", "0")).Should(BeNil())
+
+			Ω(store.TrustKey("alice", "0", &rsaKey1.PublicKey)).ShouldNot(HaveOccurred())
+			Ω(store.GetKey("alice", "0")).Should(Equal(&rsaKey1.PublicKey))
+		})
+	})
+
+	Context("Get()", func() {
+		BeforeEach(func() {
+			Ω(store.Start()).ShouldNot(HaveOccurred())
+		})
+
+		It("returns nil for other issuers", func() {
+			Ω(store.Get("bob")).Should(BeNil())
+		})
+
+		It("returns keys by ID", func() {
+			Ω(store.GetKey("alice", "1")).Should(Equal(&ecKey1.PublicKey))
+			Ω(store.GetKey("alice", "2")).Should(BeNil()) // HMAC keys are ignored
+			Ω(store.GetKey("alice", "3")).Should(BeNil())
+			Ω(store.GetKeys("alice")).Should(HaveLen(2))
+			Ω(store.GetKeys("bob")).Should(BeEmpty())
+		})
+
+		It("trusts any issuer when Issuer is empty", func() {
+			store.Issuer = ""
+			Ω(store.Get("bob")).Should(Equal(&rsaKey1.PublicKey))
+		})
+	})
+
+	It("works with the middleware", func() {
+		Ω(store.Start()).ShouldNot(HaveOccurred())
+
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		setBearerHeader(req, makeToken("alice", "bob", rsaKey1))
+
+		stack := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			return nil
+		}
+
+		middleware := jwtauth.New(commonScheme, store)
+		result := middleware(stack)(context.Background(), resp, req)
+
+		Ω(result).ShouldNot(HaveOccurred())
+	})
+})
@@ -21,6 +21,29 @@ type (
 		Get(issuer string) interface{}
 	}
 
+	// MultiKeystore is an optional extension of Keystore for keystores that
+	// can trust several keys per issuer, each identified by a Key ID.
+	//
+	// When the middleware receives a JWT whose header contains a "kid" (Key
+	// ID) parameter, and its keystore implements MultiKeystore, it verifies
+	// the JWT using the key with that ID instead of calling Get(); if the
+	// issuer has no key with that ID, it uses the key whose ID is empty. If
+	// the JWT has no Key ID, the middleware tries up to eight of the issuer's
+	// keys, starting with the last of GetKeys(), until one of them verifies
+	// the signature.
+	MultiKeystore interface {
+		Keystore
+		// TrustKey grants trust in one of an issuer's keys.
+		TrustKey(issuer, kid string, key interface{}) error
+		// RevokeKey revokes trust in one of an issuer's keys.
+		RevokeKey(issuer, kid string)
+		// GetKey returns the issuer's key that has the given ID.
+		GetKey(issuer, kid string) interface{}
+		// GetKeys returns all of the issuer's keys, from the least to the most
+		// recently trusted.
+		GetKeys(issuer string) []interface{}
+	}
+
 	// ExtractionFunc is an optional callback that allows customization of the
 	// way the middleware finds the JWT associated with each request. If your
 	// use case involves a proprietary JWT encoding, or a nonstandard location
@@ -30,4 +53,25 @@ type (
 	// AuthorizationFunc is an optional callback that allows customization
 	// of the way the middleware authorizes each request.
 	AuthorizationFunc func(context.Context, Claims) error
+
+	// RequestAuthorizationFunc is an optional callback that authorizes a
+	// request based on the request itself as well as its claims, e.g. by
+	// comparing a claim with a path parameter or header.
+	RequestAuthorizationFunc func(context.Context, *http.Request, Claims) error
+
+	// AuthenticateFunc authenticates and authorizes a request exactly as a
+	// jwtauth middleware does, returning a context that contains the claims
+	// of a valid token. See NewAuthenticator().
+	AuthenticateFunc func(context.Context, *http.Request) (context.Context, error)
+
+	// AlertFunc is an optional callback that the middleware invokes when it
+	// detects a configuration problem while processing a request, such as a
+	// trusted key whose type is unsuitable for the token's algorithm. Use it
+	// to notify operators; the request fails regardless.
+	AlertFunc func(context.Context, error)
+
+	// PrincipalFunc is an optional callback that allows customization of
+	// the way the middleware builds a Principal from a token's claims. If it
+	// returns an error, the request fails with that error.
+	PrincipalFunc func(context.Context, Claims) (*Principal, error)
 )
@@ -44,7 +44,7 @@ var _ = Describe("jwtauth Middleware", func() {
 		})
 
 		It("fails when JWTSecurity.Location is unsupported", func() {
-			scheme := &goa.JWTSecurity{In: goa.LocQuery, Name: "jwt"}
+			scheme := &goa.JWTSecurity{In: goa.Location("body"), Name: "jwt"}
 			store := &jwtauth.NamedKeystore{}
 			middleware := jwtauth.New(scheme, store)
 
@@ -53,6 +53,24 @@ var _ = Describe("jwtauth Middleware", func() {
 			Ω(result).Should(HaveResponseStatus(500))
 		})
 
+		It("reports keys of an invalid type", func() {
+			var alerted error
+			alert := func(ctx context.Context, err error) {
+				alerted = err
+			}
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{rsaKey1}, jwtauth.Alert(alert))
+			setBearerHeader(req, makeToken("alice", "bob", rsaKey1))
+
+			var result error
+			Expect(func() {
+				result = middleware(stack)(context.Background(), resp, req)
+			}).NotTo(Panic())
+
+			Ω(result).Should(HaveResponseStatus(500))
+			Ω(alerted).Should(Equal(result))
+			Ω(result.Error()).Should(ContainSubstring("*rsa.PrivateKey"))
+		})
+
 		It("converts issuers to string", func() {
 			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1})
 			claims := jwtpkg.MapClaims{}
@@ -95,6 +113,358 @@ var _ = Describe("jwtauth Middleware", func() {
 			Ω(claims).Should(HaveLen(0))
 		})
 
+		It("accepts requests that lack tokens when optional", func() {
+			middleware = jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1}, jwtauth.Required(), jwtauth.Optional())
+			result := middleware(stack)(context.Background(), resp, req)
+			Ω(result).ShouldNot(HaveOccurred())
+			Ω(claims).Should(HaveLen(0))
+		})
+
+		It("rejects requests that lack tokens when required", func() {
+			claims = nil
+			middleware = jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1}, jwtauth.Required())
+
+			result := middleware(stack)(context.Background(), resp, req)
+
+			Ω(result).Should(HaveResponseStatus(401))
+			Ω(claims).Should(BeNil())
+
+			setBearerHeader(req, makeToken("alice", "bob", hmacKey1))
+			Ω(middleware(stack)(context.Background(), resp, req)).ShouldNot(HaveOccurred())
+		})
+
+	})
+
+	Context("given a MultiKeystore", func() {
+		var resp *httptest.ResponseRecorder
+		var req *http.Request
+
+		var stack goa.Handler
+		var middleware goa.Middleware
+
+		BeforeEach(func() {
+			resp = httptest.NewRecorder()
+			req, _ = http.NewRequest("GET", "http://example.com/", nil)
+			stack = func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				return nil
+			}
+
+			store := &jwtauth.NamedKeystore{}
+			Ω(store.TrustKey("alice", "old", rsaKey1)).ShouldNot(HaveOccurred())
+			Ω(store.TrustKey("alice", "new", ecKey1)).ShouldNot(HaveOccurred())
+			middleware = jwtauth.New(commonScheme, store)
+		})
+
+		It("selects keys by ID", func() {
+			setBearerHeader(req, makeTokenWithKeyID("alice", "old", rsaKey1))
+			Ω(middleware(stack)(context.Background(), resp, req)).ShouldNot(HaveOccurred())
+
+			setBearerHeader(req, makeTokenWithKeyID("alice", "new", ecKey1))
+			Ω(middleware(stack)(context.Background(), resp, req)).ShouldNot(HaveOccurred())
+		})
+
+		It("rejects unknown key IDs", func() {
+			setBearerHeader(req, makeTokenWithKeyID("alice", "other", rsaKey1))
+
+			result := middleware(stack)(context.Background(), resp, req)
+
+			Ω(result).Should(HaveResponseStatus(401))
+		})
+
+		It("falls back to the key without an ID for unknown key IDs", func() {
+			store := &jwtauth.NamedKeystore{}
+			Ω(store.Trust("bob", hmacKey1)).ShouldNot(HaveOccurred())
+			middleware = jwtauth.New(commonScheme, store)
+
+			setBearerHeader(req, makeTokenWithKeyID("bob", "2016-11", hmacKey1))
+			Ω(middleware(stack)(context.Background(), resp, req)).ShouldNot(HaveOccurred())
+
+			setBearerHeader(req, makeTokenWithKeyID("bob", "2016-11", hmacKey2))
+			Ω(middleware(stack)(context.Background(), resp, req)).Should(HaveResponseStatus(401))
+		})
+
+		It("tries every key when the token has no ID", func() {
+			setBearerHeader(req, makeToken("alice", "bob", rsaKey1))
+			Ω(middleware(stack)(context.Background(), resp, req)).ShouldNot(HaveOccurred())
+
+			setBearerHeader(req, makeToken("alice", "bob", ecKey1))
+			Ω(middleware(stack)(context.Background(), resp, req)).ShouldNot(HaveOccurred())
+		})
+
+		It("rejects tokens that no key can verify", func() {
+			setBearerHeader(req, makeToken("alice", "bob", rsaKey2))
+
+			result := middleware(stack)(context.Background(), resp, req)
+
+			Ω(result).Should(HaveResponseStatus(401))
+		})
+
+		It("tries the newest keys first and limits the number it tries", func() {
+			store := &jwtauth.NamedKeystore{}
+			Ω(store.TrustKey("bob", "first", hmacKey2)).ShouldNot(HaveOccurred())
+			for i := 0; i < 8; i++ {
+				Ω(store.TrustKey("bob", fmt.Sprintf("%d", i), []byte(fmt.Sprintf("key %d", i)))).ShouldNot(HaveOccurred())
+			}
+			Ω(store.TrustKey("bob", "last", hmacKey1)).ShouldNot(HaveOccurred())
+			middleware = jwtauth.New(commonScheme, store)
+
+			setBearerHeader(req, makeToken("bob", "bob", hmacKey1))
+			Ω(middleware(stack)(context.Background(), resp, req)).ShouldNot(HaveOccurred())
+
+			setBearerHeader(req, makeToken("bob", "bob", hmacKey2))
+			Ω(middleware(stack)(context.Background(), resp, req)).Should(HaveResponseStatus(401))
+
+			setBearerHeader(req, makeTokenWithKeyID("bob", "first", hmacKey2))
+			Ω(middleware(stack)(context.Background(), resp, req)).ShouldNot(HaveOccurred())
+		})
+
+		It("rejects tokens signed by a different key of the same issuer", func() {
+			setBearerHeader(req, makeTokenWithKeyID("alice", "old", rsaKey2))
+
+			result := middleware(stack)(context.Background(), resp, req)
+
+			Ω(result).Should(HaveResponseStatus(401))
+		})
+	})
+
+	Context("given algorithm restrictions", func() {
+		var resp *httptest.ResponseRecorder
+		var req *http.Request
+		var stack goa.Handler
+
+		BeforeEach(func() {
+			resp = httptest.NewRecorder()
+			req, _ = http.NewRequest("GET", "http://example.com/", nil)
+			stack = func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				return nil
+			}
+		})
+
+		It("rejects HMAC tokens when the key is an RSA public key", func() {
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{&rsaKey1.PublicKey})
+			setBearerHeader(req, makeToken("alice", "bob", hmacKey1))
+
+			result := middleware(stack)(context.Background(), resp, req)
+
+			Ω(result).Should(HaveResponseStatus(401))
+		})
+
+		It("rejects HMAC tokens when the key is an ECDSA public key", func() {
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{&ecKey1.PublicKey})
+			setBearerHeader(req, makeToken("alice", "bob", hmacKey1))
+
+			result := middleware(stack)(context.Background(), resp, req)
+
+			Ω(result).Should(HaveResponseStatus(401))
+		})
+
+		It("accepts EdDSA tokens", func() {
+			store := &jwtauth.NamedKeystore{}
+			Ω(store.Trust("alice", edKey1)).Should(Succeed())
+			middleware := jwtauth.New(commonScheme, store)
+			setBearerHeader(req, makeToken("alice", "bob", edKey1))
+
+			Ω(middleware(stack)(context.Background(), resp, req)).Should(Succeed())
+
+			setBearerHeader(req, makeToken("alice", "bob", hmacKey1))
+			Ω(middleware(stack)(context.Background(), resp, req)).Should(HaveResponseStatus(401))
+		})
+
+		It("rejects EdDSA tokens with a bad signature", func() {
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{jwtauth.LoadKey(edPKIXPubPem)})
+			token := makeToken("alice", "bob", edKey1)
+			setBearerHeader(req, token[:len(token)-4]+"AAAA")
+
+			Ω(middleware(stack)(context.Background(), resp, req)).Should(HaveResponseStatus(401))
+		})
+
+		It("rejects unsigned tokens", func() {
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1}, jwtauth.Algorithms("none", "HS256"))
+			token := jwtpkg.NewWithClaims(jwtpkg.SigningMethodNone, jwtpkg.MapClaims{"iss": "alice"})
+			s, err := token.SignedString(jwtpkg.UnsafeAllowNoneSignatureType)
+			Ω(err).ShouldNot(HaveOccurred())
+			setBearerHeader(req, s)
+
+			result := middleware(stack)(context.Background(), resp, req)
+
+			Ω(result).Should(HaveResponseStatus(401))
+		})
+
+		It("rejects algorithms that are not allowed", func() {
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{&rsaKey1.PublicKey}, jwtauth.Algorithms("RS384"))
+			setBearerHeader(req, makeToken("alice", "bob", rsaKey1))
+
+			result := middleware(stack)(context.Background(), resp, req)
+
+			Ω(result).Should(HaveResponseStatus(401))
+		})
+
+		It("rejects every algorithm given an empty allowlist", func() {
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{&rsaKey1.PublicKey}, jwtauth.Algorithms())
+			setBearerHeader(req, makeToken("alice", "bob", rsaKey1))
+
+			Ω(middleware(stack)(context.Background(), resp, req)).Should(HaveResponseStatus(401))
+		})
+
+		It("narrows algorithms per issuer", func() {
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{&rsaKey1.PublicKey},
+				jwtauth.Algorithms("RS256", "RS384"),
+				jwtauth.IssuerAlgorithms("alice", "RS256"),
+				jwtauth.IssuerAlgorithms("carol", "RS384"),
+				jwtauth.IssuerAlgorithms("dave"))
+
+			setBearerHeader(req, makeToken("alice", "bob", rsaKey1))
+			Ω(middleware(stack)(context.Background(), resp, req)).ShouldNot(HaveOccurred())
+
+			setBearerHeader(req, makeToken("erin", "bob", rsaKey1))
+			Ω(middleware(stack)(context.Background(), resp, req)).ShouldNot(HaveOccurred())
+
+			setBearerHeader(req, makeToken("carol", "bob", rsaKey1))
+			Ω(middleware(stack)(context.Background(), resp, req)).Should(HaveResponseStatus(401))
+
+			setBearerHeader(req, makeToken("dave", "bob", rsaKey1))
+			Ω(middleware(stack)(context.Background(), resp, req)).Should(HaveResponseStatus(401))
+		})
+
+		It("never widens the global allowlist per issuer", func() {
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{&rsaKey1.PublicKey},
+				jwtauth.Algorithms("RS384"),
+				jwtauth.IssuerAlgorithms("alice", "RS256"))
+
+			setBearerHeader(req, makeToken("alice", "bob", rsaKey1))
+			Ω(middleware(stack)(context.Background(), resp, req)).Should(HaveResponseStatus(401))
+		})
+	})
+
+	Context("given an audience", func() {
+		var resp *httptest.ResponseRecorder
+		var req *http.Request
+		var stack goa.Handler
+		var middleware goa.Middleware
+		var authorized bool
+
+		tokenFor := func(aud interface{}) string {
+			token, err := jwtauth.NewToken(hmacKey1, jwtauth.NewClaims("iss", "alice", "aud", aud))
+			Ω(err).ShouldNot(HaveOccurred())
+			return token
+		}
+
+		BeforeEach(func() {
+			authorized = false
+			resp = httptest.NewRecorder()
+			req, _ = http.NewRequest("GET", "http://example.com/", nil)
+			stack = func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				return nil
+			}
+			auth := func(context.Context, jwtauth.Claims) error {
+				authorized = true
+				return nil
+			}
+			middleware = jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1},
+				jwtauth.Audience("bottles", "cellar"),
+				jwtauth.Authorization(auth))
+		})
+
+		It("accepts a matching string audience", func() {
+			setBearerHeader(req, tokenFor("cellar"))
+			Ω(middleware(stack)(context.Background(), resp, req)).ShouldNot(HaveOccurred())
+		})
+
+		It("accepts a matching array audience", func() {
+			setBearerHeader(req, tokenFor([]string{"vineyard", "bottles"}))
+			Ω(middleware(stack)(context.Background(), resp, req)).ShouldNot(HaveOccurred())
+		})
+
+		It("rejects other audiences before authorization", func() {
+			setBearerHeader(req, tokenFor([]string{"vineyard"}))
+
+			result := middleware(stack)(context.Background(), resp, req)
+
+			Ω(result).Should(HaveResponseStatus(401))
+			Ω(authorized).Should(BeFalse())
+		})
+
+		It("rejects tokens with no audience", func() {
+			setBearerHeader(req, makeToken("alice", "bob", hmacKey1))
+
+			result := middleware(stack)(context.Background(), resp, req)
+
+			Ω(result).Should(HaveResponseStatus(401))
+		})
+
+		It("rejects every token given no audiences", func() {
+			middleware = jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1}, jwtauth.Audience())
+			setBearerHeader(req, tokenFor("cellar"))
+
+			result := middleware(stack)(context.Background(), resp, req)
+
+			Ω(result).Should(HaveResponseStatus(401))
+		})
+
+		It("is unaffected by later changes to its arguments", func() {
+			audiences := []string{"cellar"}
+			middleware = jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1}, jwtauth.Audience(audiences...))
+			audiences[0] = "vineyard"
+
+			setBearerHeader(req, tokenFor("cellar"))
+			Ω(middleware(stack)(context.Background(), resp, req)).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Context("given a clock and leeway", func() {
+		var resp *httptest.ResponseRecorder
+		var req *http.Request
+		var stack goa.Handler
+
+		now := time.Unix(1000000000, 0)
+		clock := func() time.Time { return now }
+
+		BeforeEach(func() {
+			resp = httptest.NewRecorder()
+			req, _ = http.NewRequest("GET", "http://example.com/", nil)
+			stack = func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				return nil
+			}
+		})
+
+		It("uses the clock", func() {
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1}, jwtauth.Clock(clock))
+
+			setBearerHeader(req, makeTokenWithTimestamps("alice", "bob", hmacKey1, now, now, now.Add(time.Second)))
+			Ω(middleware(stack)(context.Background(), resp, req)).ShouldNot(HaveOccurred())
+
+			setBearerHeader(req, makeToken("alice", "bob", hmacKey1))
+			Ω(middleware(stack)(context.Background(), resp, req)).Should(HaveResponseStatus(401))
+		})
+
+		It("tolerates recently-expired tokens", func() {
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1},
+				jwtauth.Clock(clock), jwtauth.Leeway(time.Minute))
+			iat := now.Add(-time.Hour)
+
+			setBearerHeader(req, makeTokenWithTimestamps("alice", "bob", hmacKey1, iat, iat, now.Add(-30*time.Second)))
+			Ω(middleware(stack)(context.Background(), resp, req)).ShouldNot(HaveOccurred())
+
+			setBearerHeader(req, makeTokenWithTimestamps("alice", "bob", hmacKey1, iat, iat, now.Add(-90*time.Second)))
+			Ω(middleware(stack)(context.Background(), resp, req)).Should(HaveResponseStatus(401))
+		})
+
+		It("tolerates tokens that are almost valid", func() {
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1},
+				jwtauth.Clock(clock), jwtauth.Leeway(time.Minute))
+			exp := now.Add(time.Hour)
+
+			soon := now.Add(30 * time.Second)
+			setBearerHeader(req, makeTokenWithTimestamps("alice", "bob", hmacKey1, soon, soon, exp))
+			Ω(middleware(stack)(context.Background(), resp, req)).ShouldNot(HaveOccurred())
+
+			later := now.Add(90 * time.Second)
+			setBearerHeader(req, makeTokenWithTimestamps("alice", "bob", hmacKey1, now, later, exp))
+			Ω(middleware(stack)(context.Background(), resp, req)).Should(HaveResponseStatus(401))
+
+			setBearerHeader(req, makeTokenWithTimestamps("alice", "bob", hmacKey1, later, now, exp))
+			Ω(middleware(stack)(context.Background(), resp, req)).Should(HaveResponseStatus(401))
+		})
 	})
 
 	testKeyType("HMAC", hmacKey1, hmacKey2)
@@ -112,7 +482,7 @@ func testKeyType(name string, trusted, untrusted interface{}) {
 	var middleware goa.Middleware
 	var claims jwtauth.Claims
 
-	Context(fmt.Sprintf("given %s keys"), func() {
+	Context(fmt.Sprintf("given %s keys", name), func() {
 		BeforeEach(func() {
 			resp = httptest.NewRecorder()
 			req, _ = http.NewRequest("GET", "http://example.com/", nil)
@@ -0,0 +1,130 @@
+package jwtauth
+
+import (
+	"encoding/pem"
+	"fmt"
+	"regexp"
+)
+
+const (
+	// BundleIssuerHeader is the PEM header that names the issuer of a key in
+	// a bundle.
+	BundleIssuerHeader = "Issuer"
+	// BundleKidHeader is the PEM header that holds the Key ID of a key in a
+	// bundle.
+	BundleKidHeader = "Kid"
+)
+
+// pemBegin begins every PEM block, including malformed ones.
+var pemBegin = regexp.MustCompile("---+ *BEGIN ")
+
+// BundleKey is one of the keys in a PEM bundle, together with the issuer and
+// Key ID named by its block's headers. Issuer and Kid are empty if the block
+// has no such header.
+type BundleKey struct {
+	Issuer string
+	Kid    string
+	Key    interface{}
+}
+
+// LoadKeyBundle parses every PEM block in material and returns their keys in
+// order. It accepts the same block types as LoadKey. Each block may carry
+// optional "Issuer" and "Kid" headers, so that a single file can distribute
+// an issuer's current and next keys:
+//
+//     -----BEGIN PUBLIC KEY-----
+//     Issuer: us.acme.com
+//     Kid: 2016-11
+//
+//     MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE...
+//     -----END PUBLIC KEY-----
+//
+// LoadKeyBundle returns an error if material contains no PEM blocks, if any
+// of its blocks is malformed, or if two of its blocks name the same issuer
+// and Key ID.
+func LoadKeyBundle(material []byte) ([]BundleKey, error) {
+	var keys []BundleKey
+	kids := map[[2]string]bool{}
+
+	rest := material
+	for {
+		block, next := pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		// pem.Decode silently skips malformed blocks, so make sure that it
+		// consumed only the block that it returned.
+		if len(pemBegin.FindAll(rest[:len(rest)-len(next)], 2)) > 1 {
+			return nil, fmt.Errorf("PEM block %d is malformed", len(keys))
+		}
+		rest = next
+
+		key, err := parseBlock(block)
+		if err != nil {
+			return nil, fmt.Errorf("PEM block %d: %s", len(keys), err)
+		}
+		bk := BundleKey{
+			Issuer: block.Headers[BundleIssuerHeader],
+			Kid:    block.Headers[BundleKidHeader],
+			Key:    key,
+		}
+		id := [2]string{bk.Issuer, bk.Kid}
+		if bk.Kid != "" && kids[id] {
+			return nil, fmt.Errorf("PEM block %d has the same Key ID as another block for issuer '%s'", len(keys), bk.Issuer)
+		}
+		kids[id] = true
+		keys = append(keys, bk)
+	}
+
+	if pemMarker.Match(rest) {
+		return nil, fmt.Errorf("PEM block %d is malformed", len(keys))
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("Input does not appear to contain PEM blocks")
+	}
+	return keys, nil
+}
+
+// TrustKeyBundle grants trust in every key of a bundle. Keys whose block has
+// no "Issuer" header are trusted for the default issuer. Keys that have a
+// Key ID are trusted with TrustKey(), which requires store to be a
+// MultiKeystore; other keys are trusted with Trust(), so each issuer may
+// have at most one key without a Key ID.
+//
+// TrustKeyBundle checks the whole bundle before it trusts any key. If the
+// bundle is unsuitable for store, or store refuses one of its keys,
+// TrustKeyBundle returns an error and revokes the keys that it trusted, so
+// that store is left as it was.
+func TrustKeyBundle(store Keystore, issuer string, keys []BundleKey) error {
+	_, isMulti := store.(MultiKeystore)
+
+	var grants []keyGrant
+	seen := map[[2]string]bool{}
+	for i, bk := range keys {
+		iss := bundleIssuer(bk, issuer)
+		id := [2]string{iss, bk.Kid}
+		switch {
+		case iss == "":
+			return fmt.Errorf("key %d has no issuer", i)
+		case bk.Kid != "" && !isMulti:
+			return fmt.Errorf("key %d has a Key ID, but %T does not support Key IDs", i, store)
+		case bk.Kid == "" && seen[id]:
+			return fmt.Errorf("key %d is not the only key for issuer '%s' without a Key ID", i, iss)
+		case seen[id]:
+			return fmt.Errorf("key %d has the same Key ID as another key for issuer '%s'", i, iss)
+		}
+		seen[id] = true
+		grants = append(grants, keyGrant{issuer: iss, kid: bk.Kid, key: bk.Key})
+	}
+
+	return trustAll(store, grants)
+}
+
+// bundleIssuer returns the issuer of a bundled key, or def if the key's block
+// named no issuer.
+func bundleIssuer(bk BundleKey, def string) string {
+	if bk.Issuer != "" {
+		return bk.Issuer
+	}
+	return def
+}
@@ -0,0 +1,169 @@
+package jwtauth_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/pem"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	jwtauth "github.com/rightscale/goa-jwtauth"
+)
+
+// bundle concatenates PEM-encoded keys, adding "Issuer" and "Kid" headers to
+// each of them; pass empty strings to omit a header.
+func bundle(entries ...interface{}) []byte {
+	var out []byte
+	for i := 0; i < len(entries); i += 3 {
+		block, _ := pem.Decode(entries[i].([]byte))
+		block.Headers = map[string]string{}
+		if issuer := entries[i+1].(string); issuer != "" {
+			block.Headers["Issuer"] = issuer
+		}
+		if kid := entries[i+2].(string); kid != "" {
+			block.Headers["Kid"] = kid
+		}
+		out = append(out, pem.EncodeToMemory(block)...)
+	}
+	return out
+}
+
+var _ = Describe("LoadKeyBundle", func() {
+	It("loads every key in order", func() {
+		keys, err := jwtauth.LoadKeyBundle(bundle(
+			rsaPKIXPubPem, "alice", "",
+			ecPKIXPubPem, "bob", "2016-11",
+			ecCertPem, "", "",
+		))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keys).To(HaveLen(3))
+
+		Expect(keys[0].Issuer).To(Equal("alice"))
+		Expect(keys[0].Kid).To(Equal(""))
+		_, ok := keys[0].Key.(*rsa.PublicKey)
+		Expect(ok).To(BeTrue())
+
+		Expect(keys[1].Issuer).To(Equal("bob"))
+		Expect(keys[1].Kid).To(Equal("2016-11"))
+		_, ok = keys[1].Key.(*ecdsa.PublicKey)
+		Expect(ok).To(BeTrue())
+
+		Expect(keys[2].Issuer).To(Equal(""))
+		_, ok = keys[2].Key.(*ecdsa.PublicKey)
+		Expect(ok).To(BeTrue())
+	})
+
+	It("loads keys without headers", func() {
+		material := append(append([]byte{}, rsaPKIXPubPem...), ecPKIXPubPem...)
+		keys, err := jwtauth.LoadKeyBundle(material)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keys).To(HaveLen(2))
+	})
+
+	It("refuses malformed bundles", func() {
+		_, err := jwtauth.LoadKeyBundle(hmacKey1)
+		Expect(err).To(HaveOccurred())
+
+		truncated := append(append([]byte{}, rsaPKIXPubPem...), ecPKIXPubPem[:60]...)
+		_, err = jwtauth.LoadKeyBundle(truncated)
+		Expect(err).To(MatchError(ContainSubstring("PEM block 1")))
+
+		garbage := []byte("-----BEGIN DELICIOUS CHEESE-----\nyum\n-----END DELICIOUS CHEESE-----\n")
+		_, err = jwtauth.LoadKeyBundle(append(append([]byte{}, rsaPKIXPubPem...), garbage...))
+		Expect(err).To(MatchError(ContainSubstring("PEM block 1")))
+	})
+
+	It("refuses bundles with a malformed block between valid ones", func() {
+		material := append([]byte{}, rsaPKIXPubPem...)
+		material = append(material, ecPKIXPubPem[:60]...)
+		material = append(material, '\n')
+		material = append(material, ecCertPem...)
+		_, err := jwtauth.LoadKeyBundle(material)
+		Expect(err).To(MatchError(ContainSubstring("PEM block 1")))
+	})
+
+	It("refuses duplicate Key IDs for an issuer", func() {
+		_, err := jwtauth.LoadKeyBundle(bundle(
+			ecKey1Pem, "alice", "current",
+			ecKey2Pem, "alice", "current",
+		))
+		Expect(err).To(MatchError(ContainSubstring("same Key ID")))
+	})
+})
+
+var _ = Describe("TrustKeyBundle", func() {
+	It("trusts keys by issuer and Key ID", func() {
+		keys, err := jwtauth.LoadKeyBundle(bundle(
+			ecKey1Pem, "", "2016-10",
+			ecKey2Pem, "", "2016-11",
+			rsaKey1Pem, "bob", "",
+		))
+		Expect(err).NotTo(HaveOccurred())
+
+		store := &jwtauth.NamedKeystore{}
+		Expect(jwtauth.TrustKeyBundle(store, "alice", keys)).To(Succeed())
+
+		Expect(store.GetKey("alice", "2016-10")).To(Equal(&ecKey1.PublicKey))
+		Expect(store.GetKey("alice", "2016-11")).To(Equal(&ecKey2.PublicKey))
+		Expect(store.Get("bob")).To(Equal(&rsaKey1.PublicKey))
+	})
+
+	It("trusts an issuer's current and next keys", func() {
+		keys, err := jwtauth.LoadKeyBundle(bundle(
+			ecKey1Pem, "alice", "current",
+			ecKey2Pem, "alice", "next",
+		))
+		Expect(err).NotTo(HaveOccurred())
+
+		store := &jwtauth.NamedKeystore{}
+		Expect(jwtauth.TrustKeyBundle(store, "", keys)).To(Succeed())
+		Expect(store.GetKeys("alice")).To(HaveLen(2))
+	})
+
+	It("refuses unsuitable bundles without trusting any key", func() {
+		keys, err := jwtauth.LoadKeyBundle(bundle(
+			ecKey1Pem, "alice", "",
+			ecKey2Pem, "", "",
+		))
+		Expect(err).NotTo(HaveOccurred())
+
+		store := &jwtauth.NamedKeystore{}
+		Expect(jwtauth.TrustKeyBundle(store, "", keys)).To(MatchError(ContainSubstring("no issuer")))
+		Expect(jwtauth.TrustKeyBundle(store, "alice", keys)).To(MatchError(ContainSubstring("without a Key ID")))
+		Expect(store.Get("alice")).To(BeNil())
+	})
+
+	It("refuses duplicate Key IDs for the default issuer", func() {
+		keys, err := jwtauth.LoadKeyBundle(bundle(
+			ecKey1Pem, "", "current",
+			ecKey2Pem, "alice", "current",
+		))
+		Expect(err).NotTo(HaveOccurred())
+
+		store := &jwtauth.NamedKeystore{}
+		Expect(jwtauth.TrustKeyBundle(store, "alice", keys)).To(MatchError(ContainSubstring("same Key ID")))
+		Expect(store.GetKeys("alice")).To(BeEmpty())
+	})
+
+	It("revokes the keys it trusted if the store refuses one", func() {
+		keys, err := jwtauth.LoadKeyBundle(bundle(
+			ecKey1Pem, "bob", "",
+			ecKey2Pem, "alice", "current",
+		))
+		Expect(err).NotTo(HaveOccurred())
+
+		store := &jwtauth.NamedKeystore{}
+		Expect(store.TrustKey("alice", "current", &rsaKey1.PublicKey)).To(Succeed())
+		Expect(jwtauth.TrustKeyBundle(store, "", keys)).To(MatchError(ContainSubstring("RevokeKey")))
+
+		Expect(store.Get("bob")).To(BeNil())
+		Expect(store.GetKey("alice", "current")).To(Equal(&rsaKey1.PublicKey))
+	})
+
+	It("requires a MultiKeystore for Key IDs", func() {
+		keys, err := jwtauth.LoadKeyBundle(bundle(ecKey1Pem, "alice", "current"))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(jwtauth.TrustKeyBundle(&jwtauth.SimpleKeystore{}, "", keys)).To(MatchError(ContainSubstring("Key ID")))
+	})
+})
@@ -6,11 +6,43 @@ import (
 	"encoding/asn1"
 	"encoding/pem"
 	"fmt"
+	"io/ioutil"
 	"math/big"
+	"path/filepath"
 	"regexp"
+	"strings"
+	"time"
 )
 
-var pemBlock = regexp.MustCompile("^[\r\n]*---+ *BEGIN")
+// KeyType is a hint that tells ParseKey which type of key to expect.
+type KeyType string
+
+const (
+	// AnyKey accepts any PEM-encoded key, or an HMAC key if the material
+	// contains no PEM block.
+	AnyKey KeyType = ""
+	// HMACKey accepts only raw HMAC key material.
+	HMACKey KeyType = "hmac"
+	// RSAKey accepts only PEM-encoded RSA keys.
+	RSAKey KeyType = "rsa"
+	// ECDSAKey accepts only PEM-encoded ECDSA keys.
+	ECDSAKey KeyType = "ecdsa"
+	// EdDSAKey accepts only PEM-encoded Ed25519 keys.
+	EdDSAKey KeyType = "eddsa"
+)
+
+// pemMarker appears somewhere in any material that was meant to be PEM, even
+// if its header is mangled or preceded by other text.
+var pemMarker = regexp.MustCompile("---+ *(BEGIN|END) ")
+
+type (
+	// keyGrant is a key that a helper will trust for an issuer, with an
+	// optional Key ID.
+	keyGrant struct {
+		issuer, kid string
+		key         interface{}
+	}
+)
 
 // LoadKey is a helper function that transforms raw key material into a properly-
 // typed key.
@@ -19,15 +51,22 @@ var pemBlock = regexp.MustCompile("^[\r\n]*---+ *BEGIN")
 //
 // If material is a []byte that contains a PEM-encoded PKIX key (e.g. "BEGIN
 // PUBLIC KEY"), LoadKey parses it and returns a single public or private key
-// of an algorithm-specific type.
+// of an algorithm-specific type. It understands PKCS1 RSA keys, SEC1 EC
+// private keys, PKIX public keys and PKCS8 private keys, including Ed25519
+// keys.
+//
+// If material contains a PEM-encoded X.509 certificate ("BEGIN CERTIFICATE"),
+// LoadKey returns the certificate's public key without checking its validity
+// dates; use LoadCertificateKey to check them.
 //
 // If material is any other []byte, LoadKey returns it unmodified so that it can
-// be used as an HMAC key.
+// be used as an HMAC key. Material that contains a PEM header or footer
+// anywhere is never used as an HMAC key.
 //
 // Because LoadKey is designed to be used at startup, it panics if the PEM block
-// is malformed.
+// is malformed. Use ParseKey to handle errors instead.
 func LoadKey(material []byte) interface{} {
-	if pemBlock.Match(material) {
+	if pemMarker.Match(material) {
 		parsed, err := parseKey(material)
 		if err != nil {
 			panic(err)
@@ -38,26 +77,181 @@ func LoadKey(material []byte) interface{} {
 	}
 }
 
+// ParseKey is like LoadKey, but returns an error instead of panicking and is
+// stricter about the material it accepts. The hint restricts the type of key
+// that ParseKey returns; material that does not contain a key of that type
+// is an error. With AnyKey, ParseKey treats material as an HMAC key only if it
+// does not contain a PEM block anywhere, so that a truncated or mangled PEM
+// file is never mistaken for an HMAC key.
+func ParseKey(material []byte, hint KeyType) (interface{}, error) {
+	hasPEM := pemMarker.Match(material)
+
+	var key interface{}
+	switch {
+	case hint == HMACKey && hasPEM:
+		return nil, fmt.Errorf("Input appears to contain a PEM block, not an HMAC key")
+	case hasPEM:
+		parsed, err := parseKey(material)
+		if err != nil {
+			return nil, err
+		}
+		key = parsed
+	case hint == AnyKey || hint == HMACKey:
+		if len(material) == 0 {
+			return nil, fmt.Errorf("Input is empty")
+		}
+		key = material
+	default:
+		return nil, fmt.Errorf("Input does not appear to be a PEM block")
+	}
+
+	if hint != AnyKey && keyFamily(key) != string(hint) {
+		return nil, fmt.Errorf("Expected %s key, got %T", hint, key)
+	}
+	return key, nil
+}
+
+// LoadKeyFile reads a file and parses its contents with ParseKey.
+func LoadKeyFile(path string, hint KeyType) (interface{}, error) {
+	material, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParseKey(material, hint)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return key, nil
+}
+
+// LoadKeyDir trusts the keys stored in a directory, one per issuer. Each file
+// whose name ends in ".pem" holds a PEM-encoded key (or a raw HMAC key, if
+// hint is HMACKey); the file name, without its extension, is the issuer's
+// name. For example, a directory containing us.acme.com.pem and eu.acme.com.pem
+// grants trust to the issuers us.acme.com and eu.acme.com. Other files and
+// subdirectories are ignored.
+//
+// LoadKeyDir parses every key before it trusts any of them. If a key cannot be
+// loaded, or store refuses to trust one of them, LoadKeyDir returns an error
+// and revokes the keys that it trusted, so that store is left as it was.
+func LoadKeyDir(store Keystore, dir string, hint KeyType) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var grants []keyGrant
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".pem" {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		key, err := LoadKeyFile(path, hint)
+		if err != nil {
+			return err
+		}
+		if hint != HMACKey && keyFamily(key) == string(HMACKey) {
+			return fmt.Errorf("%s: Input does not appear to be a PEM block", path)
+		}
+		grants = append(grants, keyGrant{issuer: strings.TrimSuffix(name, ".pem"), key: key})
+	}
+
+	return trustAll(store, grants)
+}
+
+// trustAll grants trust in every key, or in none of them: if store refuses a
+// key, trustAll revokes the keys that it has already trusted and returns the
+// error. Keys that store trusted beforehand remain trusted. Keys that have a
+// Key ID require store to be a MultiKeystore.
+func trustAll(store Keystore, grants []keyGrant) error {
+	multi, isMulti := store.(MultiKeystore)
+
+	var granted []keyGrant
+	for _, g := range grants {
+		var known bool
+		var err error
+		switch {
+		case isMulti:
+			known = multi.GetKey(g.issuer, g.kid) != nil
+			err = multi.TrustKey(g.issuer, g.kid, g.key)
+		case g.kid != "":
+			err = fmt.Errorf("%T does not support Key IDs", store)
+		default:
+			known = store.Get(g.issuer) != nil
+			err = store.Trust(g.issuer, g.key)
+		}
+		if err != nil {
+			for i := len(granted) - 1; i >= 0; i-- {
+				if isMulti {
+					multi.RevokeKey(granted[i].issuer, granted[i].kid)
+				} else {
+					store.RevokeTrust(granted[i].issuer)
+				}
+			}
+			return err
+		}
+		if !known {
+			granted = append(granted, g)
+		}
+	}
+	return nil
+}
+
 // Parse a public key from a block of PEM-formatted ASCII text.
 func parseKey(pemBlock []byte) (interface{}, error) {
 	block, _ := pem.Decode([]byte(pemBlock))
 
 	if block != nil {
-		switch block.Type {
-		case "RSA PUBLIC KEY": // PKCS1 RSA public key
-			key := rsa.PublicKey{N: new(big.Int), E: 0}
-			_, err := asn1.Unmarshal(block.Bytes, &key)
-			return &key, err
-		case "PUBLIC KEY": // PKIX algorithm-neutral public key
-			return x509.ParsePKIXPublicKey(block.Bytes)
-		case "RSA PRIVATE KEY": // PKCS1 RSA private key
-			return x509.ParsePKCS1PrivateKey(block.Bytes)
-		case "EC PRIVATE KEY":
-			return x509.ParseECPrivateKey(block.Bytes)
-		default:
-			return nil, fmt.Errorf("Unsupported PEM block type: %s", block.Type)
-		}
+		return parseBlock(block)
 	}
 
 	return nil, fmt.Errorf("Input does not appear to be a PEM block")
 }
+
+// Parse a public or private key from a decoded PEM block.
+func parseBlock(block *pem.Block) (interface{}, error) {
+	switch block.Type {
+	case "RSA PUBLIC KEY": // PKCS1 RSA public key
+		key := rsa.PublicKey{N: new(big.Int), E: 0}
+		_, err := asn1.Unmarshal(block.Bytes, &key)
+		return &key, err
+	case "PUBLIC KEY": // PKIX algorithm-neutral public key
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PRIVATE KEY": // PKCS1 RSA private key
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY": // PKCS8 algorithm-neutral private key
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	default:
+		return nil, fmt.Errorf("Unsupported PEM block type: %s", block.Type)
+	}
+}
+
+// LoadCertificateKey parses a PEM-encoded X.509 certificate and returns its
+// public key, provided that the certificate is valid at the specified time
+// (typically time.Now()). It does not verify the certificate's chain of trust.
+func LoadCertificateKey(material []byte, at time.Time) (interface{}, error) {
+	block, _ := pem.Decode(material)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("Input does not appear to be a PEM-encoded certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if at.Before(cert.NotBefore) {
+		return nil, fmt.Errorf("Certificate is not valid until %s", cert.NotBefore.Format(time.RFC3339))
+	}
+	if at.After(cert.NotAfter) {
+		return nil, fmt.Errorf("Certificate expired at %s", cert.NotAfter.Format(time.RFC3339))
+	}
+	return cert.PublicKey, nil
+}
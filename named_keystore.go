@@ -3,6 +3,7 @@ package jwtauth
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"fmt"
 	"reflect"
@@ -13,11 +14,21 @@ type (
 	// NamedKeystore is a concurrency-safe, in-memory Keystore implementation
 	// that allows trust to be granted/revoked from issuers at any time.
 	//
+	// NamedKeystore implements MultiKeystore; each issuer may have several
+	// keys, distinguished by their Key IDs. Keys trusted with Trust() have an
+	// empty Key ID.
+	//
 	// All methods are safe to call on the zero value of this type; fields are
 	// initialized as needed.
 	NamedKeystore struct {
 		sync.RWMutex
-		keys map[string]interface{}
+		keys map[string][]namedKey
+	}
+
+	// namedKey is a key trusted by a NamedKeystore, together with its Key ID.
+	namedKey struct {
+		kid string
+		key interface{}
 	}
 
 	privateKey interface {
@@ -29,23 +40,22 @@ type (
 //	   - []byte (for HS tokens)
 //     - *rsa.PublicKey (for RS tokens)
 //     - *ecdsa.PublicKey (for ES tokens)
+//     - ed25519.PublicKey (for EdDSA tokens)
 //
 // As a convenience, it converts the following to a related type:
 //     - string becomes []byte
 //     - *rsa.PrivateKey becomes its public key
 //     - *ecdsa.PrivateKey becomes its public key
+//     - ed25519.PrivateKey becomes its public key
+//
+// Trust is equivalent to calling TrustKey with an empty Key ID.
 func (nk *NamedKeystore) Trust(issuer string, key interface{}) error {
-	nk.Lock()
-	defer nk.Unlock()
-
-	if nk.keys == nil {
-		nk.keys = map[string]interface{}{}
-	}
-
-	if old, ok := nk.keys[issuer]; ok && !reflect.DeepEqual(old, key) {
-		return fmt.Errorf("already added a key for issuer '%s'; call RemoveKey first", issuer)
-	}
+	return nk.TrustKey(issuer, "", key)
+}
 
+// TrustKey grants trust in one of an issuer's keys, identified by kid. It
+// accepts the same key types as Trust().
+func (nk *NamedKeystore) TrustKey(issuer, kid string, key interface{}) error {
 	// For convenience, turn private keys into public and strings into bytes.
 	switch kt := key.(type) {
 	case privateKey:
@@ -54,16 +64,34 @@ func (nk *NamedKeystore) Trust(issuer string, key interface{}) error {
 		key = []byte(kt)
 	}
 
-	switch kt := key.(type) {
-	case *rsa.PublicKey, *ecdsa.PublicKey, []byte:
-		nk.keys[issuer] = kt
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey, []byte:
 	default:
 		return fmt.Errorf("unsupported key type %T", key)
 	}
 
+	nk.Lock()
+	defer nk.Unlock()
+
+	if nk.keys == nil {
+		nk.keys = map[string][]namedKey{}
+	}
+
+	for _, nkey := range nk.keys[issuer] {
+		if nkey.kid == kid {
+			if !reflect.DeepEqual(nkey.key, key) {
+				return fmt.Errorf("already added a key for issuer '%s' with ID '%s'; call RevokeKey first", issuer, kid)
+			}
+			return nil
+		}
+	}
+
+	nk.keys[issuer] = append(nk.keys[issuer], namedKey{kid: kid, key: key})
+
 	return nil
 }
 
+// RevokeTrust revokes trust in all of an issuer's keys.
 func (nk *NamedKeystore) RevokeTrust(issuer string) {
 	nk.Lock()
 	defer nk.Unlock()
@@ -76,13 +104,78 @@ func (nk *NamedKeystore) RevokeTrust(issuer string) {
 	return
 }
 
+// RevokeKey revokes trust in one of an issuer's keys.
+func (nk *NamedKeystore) RevokeKey(issuer, kid string) {
+	nk.Lock()
+	defer nk.Unlock()
+
+	if nk.keys == nil {
+		return
+	}
+
+	var kept []namedKey
+	for _, nkey := range nk.keys[issuer] {
+		if nkey.kid != kid {
+			kept = append(kept, nkey)
+		}
+	}
+
+	if len(kept) > 0 {
+		nk.keys[issuer] = kept
+	} else {
+		delete(nk.keys, issuer)
+	}
+}
+
+// Get returns the issuer's key that has an empty Key ID or, if there is no
+// such key, the issuer's earliest-trusted key.
 func (nk *NamedKeystore) Get(issuer string) interface{} {
 	nk.RLock()
 	defer nk.RUnlock()
 
+	if nk.keys == nil || len(nk.keys[issuer]) == 0 {
+		return nil
+	}
+
+	for _, nkey := range nk.keys[issuer] {
+		if nkey.kid == "" {
+			return nkey.key
+		}
+	}
+
+	return nk.keys[issuer][0].key
+}
+
+// GetKey returns the issuer's key that has the given Key ID.
+func (nk *NamedKeystore) GetKey(issuer, kid string) interface{} {
+	nk.RLock()
+	defer nk.RUnlock()
+
 	if nk.keys != nil {
-		return nk.keys[issuer]
+		for _, nkey := range nk.keys[issuer] {
+			if nkey.kid == kid {
+				return nkey.key
+			}
+		}
 	}
 
 	return nil
 }
+
+// GetKeys returns all of the issuer's keys, in the order in which they were
+// trusted.
+func (nk *NamedKeystore) GetKeys(issuer string) []interface{} {
+	nk.RLock()
+	defer nk.RUnlock()
+
+	if nk.keys == nil || len(nk.keys[issuer]) == 0 {
+		return nil
+	}
+
+	keys := make([]interface{}, len(nk.keys[issuer]))
+	for i, nkey := range nk.keys[issuer] {
+		keys[i] = nkey.key
+	}
+
+	return keys
+}
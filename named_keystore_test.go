@@ -68,5 +68,61 @@ var _ = Describe("NamedKeystore", func() {
 			Ω(store.Get("moo")).Should(Equal(hmacKey1))
 			Ω(store.Get("bah")).Should(BeNil())
 		})
+
+		It("prefers the key without an ID", func() {
+			Ω(store.TrustKey("moo", "k1", hmacKey2)).ShouldNot(HaveOccurred())
+			Ω(store.Get("moo")).Should(Equal(hmacKey1))
+		})
+
+		It("falls back to the earliest key with an ID", func() {
+			Ω(store.TrustKey("bah", "k1", hmacKey2)).ShouldNot(HaveOccurred())
+			Ω(store.TrustKey("bah", "k2", hmacKey1)).ShouldNot(HaveOccurred())
+			Ω(store.Get("bah")).Should(Equal(hmacKey2))
+		})
+	})
+
+	Context("TrustKey()", func() {
+		It("accepts several keys per issuer", func() {
+			Ω(store.TrustKey("moo", "k1", hmacKey2)).ShouldNot(HaveOccurred())
+			Ω(store.TrustKey("moo", "k2", rsaKey1)).ShouldNot(HaveOccurred())
+			Ω(store.GetKey("moo", "k1")).Should(Equal(hmacKey2))
+			Ω(store.GetKey("moo", "k2")).Should(Equal(&rsaKey1.PublicKey))
+		})
+
+		It("tolerates idempotent double-add", func() {
+			Ω(store.TrustKey("moo", "k1", rsaKey1)).ShouldNot(HaveOccurred())
+			Ω(store.TrustKey("moo", "k1", rsaKey1)).ShouldNot(HaveOccurred())
+		})
+
+		It("rejects double-add", func() {
+			Ω(store.TrustKey("moo", "k1", hmacKey2)).ShouldNot(HaveOccurred())
+			Ω(store.TrustKey("moo", "k1", hmacKey1)).Should(HaveOccurred())
+		})
+	})
+
+	Context("RevokeKey()", func() {
+		It("removes only the specified key", func() {
+			Ω(store.TrustKey("moo", "k1", hmacKey2)).ShouldNot(HaveOccurred())
+			store.RevokeKey("moo", "k1")
+			Ω(store.GetKey("moo", "k1")).Should(BeNil())
+			Ω(store.Get("moo")).Should(Equal(hmacKey1))
+		})
+	})
+
+	Context("GetKeys()", func() {
+		It("returns keys in the order they were trusted", func() {
+			Ω(store.TrustKey("moo", "k1", rsaKey1)).ShouldNot(HaveOccurred())
+			Ω(store.TrustKey("moo", "k0", ecKey1)).ShouldNot(HaveOccurred())
+			Ω(store.GetKeys("moo")).Should(Equal([]interface{}{hmacKey1, &rsaKey1.PublicKey, &ecKey1.PublicKey}))
+			Ω(store.GetKeys("bah")).Should(BeEmpty())
+		})
+	})
+
+	Context("GetKey()", func() {
+		It("requires a matching ID", func() {
+			Ω(store.GetKey("moo", "")).Should(Equal(hmacKey1))
+			Ω(store.GetKey("moo", "k1")).Should(BeNil())
+			Ω(store.GetKey("bah", "")).Should(BeNil())
+		})
 	})
 })
@@ -3,6 +3,7 @@ package jwtauth
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/goadesign/goa"
@@ -12,46 +13,49 @@ import (
 // New creates a jwtauth middleware with the specified security scheme,
 // keystore, and options.
 func New(scheme *goa.JWTSecurity, store Keystore, options ...Option) goa.Middleware {
+	oo := newOptions(scheme, store, options)
+
+	return func(nextHandler goa.Handler) goa.Handler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+			ctx, err := authenticate(ctx, oo, req)
+			if err == nil {
+				return nextHandler(ctx, rw, req)
+			}
+			setChallenge(ctx, oo, rw, err)
+			return err
+		}
+	}
+}
+
+// NewAuthenticator creates an AuthenticateFunc with the specified security
+// scheme, keystore, and options. It lets adapters for other frameworks, such
+// as the grpcauth package, apply the same checks as New(). Unlike the
+// middleware, it sends no WWW-Authenticate challenge.
+func NewAuthenticator(scheme *goa.JWTSecurity, store Keystore, options ...Option) AuthenticateFunc {
+	oo := newOptions(scheme, store, options)
+
+	return func(ctx context.Context, req *http.Request) (context.Context, error) {
+		return authenticate(ctx, oo, req)
+	}
+}
+
+// newOptions applies the specified options on top of the default behavior.
+func newOptions(scheme *goa.JWTSecurity, store Keystore, options []Option) *mwopts {
 	oo := &mwopts{}
 	oo.Scheme = scheme
 	oo.Keystore = store
 	oo.Extraction = DefaultExtraction
 	oo.Authorization = DefaultAuthorization
+	oo.Principal = DefaultPrincipal
+	oo.Clock = time.Now
 
 	for _, o := range options {
 		o(oo)
 	}
-
-	return func(nextHandler goa.Handler) goa.Handler {
-		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
-			token, err := parseToken(oo.Scheme, oo.Keystore, oo.Extraction, req)
-			if err != nil {
-				return err
-			}
-
-			claims := Claims{}
-			if token != nil {
-				switch tc := token.Claims.(type) {
-				case jwt.MapClaims:
-					claims = Claims(tc)
-				default:
-					// this is impossible; jwt only produces MapClaims when parsing
-					panic(fmt.Sprintf("unsupported jwt.Claims type %T", tc))
-				}
-			}
-
-			ctx = WithClaims(ctx, claims)
-
-			if oo.Authorization != nil {
-				err = oo.Authorization(ctx, claims)
-			}
-
-			if err == nil {
-				return nextHandler(ctx, rw, req)
-			}
-			return err
-		}
+	if oo.Realm == "" && scheme != nil {
+		oo.Realm = scheme.Description
 	}
+	return oo
 }
 
 // NewToken creates a JWT with the specified claims and signs it using
@@ -0,0 +1,50 @@
+package jwtauth
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/goadesign/goa"
+)
+
+// NewHTTP creates a jwtauth middleware for plain net/http handlers, e.g. for
+// routes that are mounted outside of goa. It accepts the same security
+// scheme, keystore and options as New(), and behaves identically: the claims
+// of a valid token are available to the next handler via
+// ContextClaims(req.Context()).
+//
+// When the middleware rejects a request, it responds with the status of the
+// error's class and a goa error document, and does not call the next handler.
+//
+// There are no goa actions outside of goa, so the default authorization
+// behavior requires no scopes unless the request context already contains
+// some (see goa.WithRequiredScopes).
+func NewHTTP(scheme *goa.JWTSecurity, store Keystore, options ...Option) func(http.Handler) http.Handler {
+	oo := newOptions(scheme, store, options)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			ctx, err := authenticate(req.Context(), oo, req)
+			if err != nil {
+				setChallenge(ctx, oo, rw, err)
+				writeError(rw, err)
+				return
+			}
+			next.ServeHTTP(rw, req.WithContext(ctx))
+		})
+	}
+}
+
+// writeError responds with a goa error document that describes err. Errors
+// that are not goa errors are reported as an internal error without detail.
+func writeError(rw http.ResponseWriter, err error) {
+	gerr := errorResponse(err)
+	if gerr == nil {
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", goa.ErrorMediaIdentifier)
+	rw.WriteHeader(gerr.Status)
+	json.NewEncoder(rw).Encode(gerr)
+}
@@ -0,0 +1,72 @@
+package jwtauth_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/goadesign/goa"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rightscale/goa-jwtauth"
+)
+
+var _ = Describe("NewHTTP()", func() {
+	var resp *httptest.ResponseRecorder
+	var req *http.Request
+	var called bool
+	var claims jwtauth.Claims
+	var handler http.Handler
+
+	BeforeEach(func() {
+		resp = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "http://example.com/", nil)
+		called, claims = false, nil
+		next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			called = true
+			claims = jwtauth.ContextClaims(r.Context())
+		})
+		handler = jwtauth.NewHTTP(commonScheme, &jwtauth.SimpleKeystore{hmacKey1})(next)
+	})
+
+	It("accepts requests that lack tokens", func() {
+		handler.ServeHTTP(resp, req)
+
+		Ω(called).Should(BeTrue())
+		Ω(claims).ShouldNot(BeNil())
+		Ω(claims).Should(BeEmpty())
+	})
+
+	It("adds claims to the request context", func() {
+		setBearerHeader(req, makeToken("alice", "bob", hmacKey1))
+		handler.ServeHTTP(resp, req)
+
+		Ω(called).Should(BeTrue())
+		Ω(claims.Issuer()).Should(Equal("alice"))
+		Ω(claims.Subject()).Should(Equal("bob"))
+	})
+
+	It("rejects invalid tokens", func() {
+		setBearerHeader(req, makeToken("alice", "bob", hmacKey2))
+		handler.ServeHTTP(resp, req)
+
+		Ω(called).Should(BeFalse())
+		Ω(resp.Code).Should(Equal(401))
+		Ω(resp.Header().Get("Content-Type")).Should(Equal(goa.ErrorMediaIdentifier))
+		Ω(resp.Header().Get("WWW-Authenticate")).Should(HavePrefix(`Bearer error="invalid_token"`))
+
+		var body map[string]interface{}
+		Ω(json.Unmarshal(resp.Body.Bytes(), &body)).Should(Succeed())
+		Ω(body["code"]).Should(Equal("invalid_token"))
+	})
+
+	It("authorizes requests", func() {
+		req = req.WithContext(goa.WithRequiredScopes(req.Context(), []string{"write"}))
+		setBearerHeader(req, makeToken("alice", "bob", hmacKey1, "read"))
+		handler.ServeHTTP(resp, req)
+
+		Ω(called).Should(BeFalse())
+		Ω(resp.Code).Should(Equal(403))
+		Ω(resp.Header().Get("WWW-Authenticate")).Should(ContainSubstring(`scope="write"`))
+	})
+})
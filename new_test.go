@@ -1,11 +1,15 @@
 package jwtauth_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"net/http"
 	"net/http/httptest"
 
 	"golang.org/x/net/context"
 
+	jwtpkg "github.com/dgrijalva/jwt-go"
 	"github.com/goadesign/goa"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -47,6 +51,7 @@ var _ = Describe("New()", func() {
 
 		Ω(calledAuth).Should(BeTrue())
 		Ω(calledExtract).Should(BeTrue())
+		Ω(claims).Should(HaveLen(0))
 	})
 })
 
@@ -59,4 +64,29 @@ var _ = Describe("NewToken()", func() {
 	It("accepts known key types", func() {
 
 	})
+
+	It("chooses an ECDSA algorithm by curve", func() {
+		key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		tok, err := jwtauth.NewToken(key, jwtauth.Claims{})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		parsed, err := jwtpkg.Parse(tok, func(*jwtpkg.Token) (interface{}, error) {
+			return &key.PublicKey, nil
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(parsed.Method.Alg()).Should(Equal("ES384"))
+	})
+
+	It("signs with Ed25519 keys", func() {
+		tok, err := jwtauth.NewToken(edKey1, jwtauth.Claims{})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		parsed, err := jwtpkg.Parse(tok, func(*jwtpkg.Token) (interface{}, error) {
+			return jwtauth.LoadKey(edPKIXPubPem), nil
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(parsed.Method.Alg()).Should(Equal("EdDSA"))
+	})
 })
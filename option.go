@@ -1,6 +1,10 @@
 package jwtauth
 
-import "github.com/goadesign/goa"
+import (
+	"time"
+
+	"github.com/goadesign/goa"
+)
 
 type (
 	// mwopts is a state accumulator for Option.
@@ -9,6 +13,19 @@ type (
 		Keystore      Keystore
 		Extraction    ExtractionFunc
 		Authorization AuthorizationFunc
+		Algorithms    []string
+		IssuerAlgs    map[string][]string
+		Alert         AlertFunc
+		Audiences     []string
+		Leeway        time.Duration
+		Clock         func() time.Time
+		Required      bool
+		Realm         string
+		Principal     PrincipalFunc
+		ScopeMatcher  ScopeMatcher
+		ScopeClaims   []string
+
+		RequestAuthorization RequestAuthorizationFunc
 	}
 
 	// Option is a function that applies options. Its signature contains unexported
@@ -36,3 +53,157 @@ func Authorization(fn AuthorizationFunc) Option {
 		o.Authorization = fn
 	}
 }
+
+// RequestAuthorization installs a request-aware authorization function that a
+// jwtauth middleware calls after its AuthorizationFunc passes the request. The
+// request must satisfy both. See RequestRules() to combine several rules.
+//
+// The default behavior is to perform no request-aware authorization.
+func RequestAuthorization(fn RequestAuthorizationFunc) Option {
+	return func(o *mwopts) {
+		o.RequestAuthorization = fn
+	}
+}
+
+// Algorithms restricts the JWT signing algorithms (e.g. "RS256", "ES384") that
+// a jwtauth middleware accepts. Tokens that use any other algorithm are
+// rejected with ErrInvalidToken. Calling Algorithms() with no arguments
+// rejects every token.
+//
+// The default behavior is to accept any algorithm that is suitable for the
+// issuer's key. The "none" algorithm is never accepted.
+func Algorithms(algs ...string) Option {
+	return func(o *mwopts) {
+		o.Algorithms = append([]string{}, algs...)
+	}
+}
+
+// IssuerAlgorithms further restricts the JWT signing algorithms that a jwtauth
+// middleware accepts for tokens from a specific issuer. An issuer may only use
+// algorithms that both IssuerAlgorithms() and Algorithms() allow. Calling
+// IssuerAlgorithms() with no algorithms rejects every token from the issuer.
+//
+// The default behavior is to accept the algorithms allowed by Algorithms().
+func IssuerAlgorithms(issuer string, algs ...string) Option {
+	return func(o *mwopts) {
+		if o.IssuerAlgs == nil {
+			o.IssuerAlgs = map[string][]string{}
+		}
+		o.IssuerAlgs[issuer] = append([]string{}, algs...)
+	}
+}
+
+// Alert installs a callback that a jwtauth middleware invokes whenever it
+// detects a configuration problem, such as a keystore that holds a key of the
+// wrong type for an issuer's tokens. The request fails with ErrUnsupported
+// whether or not an alert callback is installed.
+//
+// The default behavior is to invoke no callback.
+func Alert(fn AlertFunc) Option {
+	return func(o *mwopts) {
+		o.Alert = fn
+	}
+}
+
+// Audience requires every token to have an "aud" (Audience) claim that
+// contains at least one of the given audiences, typically the identifier of
+// your service. Tokens that fail this test are rejected with ErrInvalidToken
+// before authorization takes place.
+//
+// The "aud" claim may be either a single string or an array of strings.
+// Calling Audience() with no arguments rejects every token.
+//
+// The default behavior is to ignore the "aud" claim.
+func Audience(audiences ...string) Option {
+	return func(o *mwopts) {
+		o.Audiences = append([]string{}, audiences...)
+	}
+}
+
+// Leeway allows for clock skew between the token issuer and your service by
+// tolerating tokens that are up to d past their "exp" (Expiration Time), or
+// up to d before their "nbf" (Not Before) or "iat" (Issued At) time.
+//
+// The default behavior is to allow no leeway.
+func Leeway(d time.Duration) Option {
+	return func(o *mwopts) {
+		o.Leeway = d
+	}
+}
+
+// Clock overrides the source of the current time that a jwtauth middleware
+// uses to check a token's "exp", "nbf" and "iat" claims, e.g. to make tests
+// deterministic.
+//
+// The default behavior is to call time.Now().
+func Clock(fn func() time.Time) Option {
+	return func(o *mwopts) {
+		o.Clock = fn
+	}
+}
+
+// Required causes a jwtauth middleware to reject requests that carry no JWT
+// with ErrInvalidToken, regardless of whether the requested action requires
+// any scopes.
+func Required() Option {
+	return func(o *mwopts) {
+		o.Required = true
+	}
+}
+
+// Optional causes a jwtauth middleware to pass requests that carry no JWT on
+// to its AuthorizationFunc, with empty claims. DefaultAuthorization passes
+// such requests only if the requested action requires no scopes.
+//
+// This is the default behavior.
+func Optional() Option {
+	return func(o *mwopts) {
+		o.Required = false
+	}
+}
+
+// Realm sets the "realm" attribute of the WWW-Authenticate challenges that a
+// jwtauth middleware sends when authentication or authorization fails.
+//
+// The default behavior is to use the description of the middleware's goa
+// security scheme as the realm, or to omit the realm if the scheme has no
+// description.
+func Realm(realm string) Option {
+	return func(o *mwopts) {
+		o.Realm = realm
+	}
+}
+
+// PrincipalMapping overrides the way a jwtauth middleware builds a Principal
+// from the claims of each valid token, e.g. to read a tenant from a
+// nonstandard claim or to look up the user's display name.
+//
+// The default behavior is to call the DefaultPrincipal() function.
+func PrincipalMapping(fn PrincipalFunc) Option {
+	return func(o *mwopts) {
+		o.Principal = fn
+	}
+}
+
+// ScopeMatching selects the ScopeMatcher that DefaultAuthorization uses to
+// decide whether the scopes claimed by a token satisfy the scopes required by
+// an action, e.g. WildcardScopes or a ScopeGraph.
+//
+// The default behavior is to use ExactScopes.
+func ScopeMatching(m ScopeMatcher) Option {
+	return func(o *mwopts) {
+		o.ScopeMatcher = m
+	}
+}
+
+// ScopeClaims names the claims from which a jwtauth middleware reads the
+// scopes that a token holds, e.g. "scope" for OAuth2 tokens or "scp" for Azure
+// AD tokens. Each claim may be an array of scopes or a space-delimited string;
+// if several claims are named, their scopes are combined. See ClaimedScopes().
+//
+// The default behavior is to read the ScopesClaim.
+func ScopeClaims(names ...string) Option {
+	return func(o *mwopts) {
+		o.ScopeClaims = names
+	}
+}
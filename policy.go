@@ -0,0 +1,578 @@
+package jwtauth
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/goadesign/goa"
+	"golang.org/x/net/context"
+)
+
+// policyVariables are the variables that a policy expression may use.
+var policyVariables = []string{"claims", "scopes", "required", "params", "request", "controller", "action"}
+
+type (
+	// Policy is an authorization rule written in a small expression language,
+	// so that rules can live in configuration rather than in code:
+	//
+	//     "bottle:drink" in scopes && claims.tenant == params.tenant
+	//
+	// Expressions may use these variables:
+	//
+	//     claims      the token's claims, e.g. claims.sub or claims["https://acme.com/org"]
+	//     scopes      the scopes claimed by the token (see ClaimedScopes)
+	//     required    the scopes required by the goa action
+	//     params      the goa request's path and query-string parameters
+	//     request     request.method, request.path, request.host and request.header.X-Name
+	//     controller  the name of the goa controller
+	//     action      the name of the goa action
+	//
+	// Literals may be strings in single or double quotes, numbers, true, false,
+	// null and lists such as ["admin", "owner"]. The operators are, from
+	// lowest to highest precedence: ||, &&, ! and the comparisons ==, != and
+	// in, which tests whether a value is an element of a list. Parentheses
+	// group subexpressions.
+	//
+	// Absent claims and parameters are null. An absent value equals only the
+	// null literal: ==, != and in are false whenever one of their operands is
+	// absent, so that e.g. claims.tenant == params.tenant denies a request
+	// that carries neither a tenant claim nor a tenant parameter. Use
+	// claims.tenant == null or claims.tenant != null to test for presence.
+	//
+	// In a boolean context, null, false, "", 0 and empty lists are false;
+	// every other value is true.
+	Policy struct {
+		source string
+		root   policyNode
+	}
+
+	// policyNode is a node of a compiled policy expression.
+	policyNode interface {
+		eval(env *policyEnv) interface{}
+	}
+
+	// policyEnv holds the variables of a policy expression during evaluation.
+	policyEnv struct {
+		ctx    context.Context
+		claims Claims
+	}
+
+	policyLiteral struct {
+		value interface{}
+	}
+
+	policyVariable struct {
+		name string
+		path []string
+	}
+
+	policyList struct {
+		items []policyNode
+	}
+
+	policyNot struct {
+		operand policyNode
+	}
+
+	policyBinary struct {
+		op          string
+		left, right policyNode
+	}
+)
+
+// CompilePolicy compiles a policy expression. It returns an error that
+// describes the problem and its position if the expression is malformed or
+// uses an unknown variable. Compile your policies once, at startup.
+func CompilePolicy(expr string) (*Policy, error) {
+	tokens, err := lexPolicy(expr)
+	if err != nil {
+		return nil, fmt.Errorf("policy %q: %s", expr, err)
+	}
+	p := &policyParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != tokEOF {
+		err = p.unexpected("end of expression")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("policy %q: %s", expr, err)
+	}
+	return &Policy{source: expr, root: root}, nil
+}
+
+// MustCompilePolicy is like CompilePolicy, but panics if the expression
+// cannot be compiled. It simplifies the initialization of global policies.
+func MustCompilePolicy(expr string) *Policy {
+	p, err := CompilePolicy(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the policy's expression.
+func (p *Policy) String() string {
+	return p.source
+}
+
+// Authorize is an AuthorizationFunc that passes the request if the policy's
+// expression is true, and otherwise responds with ErrAuthorizationFailed.
+func (p *Policy) Authorize(ctx context.Context, claims Claims) error {
+	if truthy(p.root.eval(&policyEnv{ctx: ctx, claims: claims})) {
+		return nil
+	}
+	return ErrAuthorizationFailed("policy denied", "policy", p.source)
+}
+
+func (n *policyLiteral) eval(env *policyEnv) interface{} {
+	return n.value
+}
+
+func (n *policyVariable) eval(env *policyEnv) interface{} {
+	var v interface{}
+	switch n.name {
+	case "claims":
+		v = env.claims
+	case "scopes":
+		v = ClaimedScopes(env.ctx, env.claims)
+	case "required":
+		v = goa.ContextRequiredScopes(env.ctx)
+	case "params":
+		if rd := goa.ContextRequest(env.ctx); rd != nil && rd.Params != nil {
+			v = rd.Params
+		} else if req := contextRequest(env.ctx); req != nil {
+			v = req.URL.Query()
+		}
+	case "request":
+		if req := contextRequest(env.ctx); req != nil {
+			v = map[string]interface{}{
+				"method": req.Method,
+				"path":   req.URL.Path,
+				"host":   req.Host,
+				"header": req.Header,
+			}
+		}
+	case "controller":
+		v = goa.ContextController(env.ctx)
+	case "action":
+		v = goa.ContextAction(env.ctx)
+	}
+
+	for _, key := range n.path {
+		v = policyIndex(v, key)
+	}
+	return v
+}
+
+func (n *policyList) eval(env *policyEnv) interface{} {
+	items := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		items[i] = item.eval(env)
+	}
+	return items
+}
+
+func (n *policyNot) eval(env *policyEnv) interface{} {
+	return !truthy(n.operand.eval(env))
+}
+
+func (n *policyBinary) eval(env *policyEnv) interface{} {
+	switch n.op {
+	case "||":
+		return truthy(n.left.eval(env)) || truthy(n.right.eval(env))
+	case "&&":
+		return truthy(n.left.eval(env)) && truthy(n.right.eval(env))
+	case "==", "!=":
+		left, right := n.left.eval(env), n.right.eval(env)
+		if isNullLiteral(n.left) || isNullLiteral(n.right) {
+			return (left == nil && right == nil) == (n.op == "==")
+		}
+		if left == nil || right == nil {
+			return false
+		}
+		return policyEqual(left, right) == (n.op == "==")
+	case "in":
+		needle := n.left.eval(env)
+		for _, v := range policyElements(n.right.eval(env)) {
+			if policyEqual(needle, v) {
+				return true
+			}
+		}
+		return false
+	default:
+		panic("unknown policy operator " + n.op)
+	}
+}
+
+// isNullLiteral determines whether a node is the literal null, which is the
+// only value that an absent value equals.
+func isNullLiteral(n policyNode) bool {
+	lit, ok := n.(*policyLiteral)
+	return ok && lit.value == nil
+}
+
+// policyIndex looks up a key in a map-like value; it returns nil if the value
+// is not map-like or has no such key.
+func policyIndex(v interface{}, key string) interface{} {
+	switch tv := v.(type) {
+	case Claims:
+		return tv[key]
+	case map[string]interface{}:
+		return tv[key]
+	case url.Values:
+		if vs, ok := tv[key]; ok && len(vs) > 0 {
+			return vs[0]
+		}
+	case http.Header:
+		if vs, ok := tv[http.CanonicalHeaderKey(key)]; ok && len(vs) > 0 {
+			return vs[0]
+		}
+	}
+	return nil
+}
+
+// policyElements returns the elements of a list; a scalar is a list of one
+// element, and null is an empty list.
+func policyElements(v interface{}) []interface{} {
+	switch tv := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return tv
+	case []string:
+		elems := make([]interface{}, len(tv))
+		for i, s := range tv {
+			elems[i] = s
+		}
+		return elems
+	default:
+		return []interface{}{tv}
+	}
+}
+
+// policyEqual compares two values. Scalars are compared by their string
+// representation, so that e.g. the number 7 equals the claim "7"; lists are
+// equal if their elements are equal. An absent value equals nothing.
+func policyEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	switch a.(type) {
+	case []interface{}, []string:
+		ea, eb := policyElements(a), policyElements(b)
+		if len(ea) != len(eb) {
+			return false
+		}
+		for i := range ea {
+			if !policyEqual(ea[i], eb[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	switch b.(type) {
+	case []interface{}, []string:
+		return policyEqual(b, a)
+	}
+	return stringify(a) == stringify(b)
+}
+
+// truthy determines whether a value is true in a boolean context.
+func truthy(v interface{}) bool {
+	switch tv := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return tv
+	case string:
+		return tv != ""
+	case float64:
+		return tv != 0
+	case []interface{}:
+		return len(tv) > 0
+	case []string:
+		return len(tv) > 0
+	default:
+		return true
+	}
+}
+
+// Lexical analysis of policy expressions.
+
+type policyTokenKind int
+
+const (
+	tokEOF policyTokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOperator
+)
+
+type policyToken struct {
+	kind   policyTokenKind
+	text   string
+	offset int
+}
+
+// describe describes the token for use in error messages.
+func (t policyToken) describe() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// lexPolicy splits a policy expression into tokens.
+func lexPolicy(expr string) ([]policyToken, error) {
+	var tokens []policyToken
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			text, n, err := lexString(expr[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%s at offset %d", err, i)
+			}
+			tokens = append(tokens, policyToken{tokString, text, i})
+			i += n
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(expr) && (expr[j] >= '0' && expr[j] <= '9' || expr[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, policyToken{tokNumber, expr[i:j], i})
+			i = j
+		case c == '_' || unicode.IsLetter(c):
+			j := i
+			for j < len(expr) && isIdentChar(rune(expr[j])) {
+				j++
+			}
+			tokens = append(tokens, policyToken{tokIdent, expr[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", "!", "(", ")", "[", "]", ",", "."} {
+				if strings.HasPrefix(expr[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+			}
+			tokens = append(tokens, policyToken{tokOperator, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, policyToken{tokEOF, "", len(expr)}), nil
+}
+
+// lexString scans a quoted string at the beginning of s. It returns the
+// string's value and its length in s.
+func lexString(s string) (string, int, error) {
+	quote := s[0]
+	var value []byte
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case quote:
+			return string(value), i + 1, nil
+		case '\\':
+			if i+1 < len(s) {
+				i++
+			}
+		}
+		value = append(value, s[i])
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// isIdentChar determines whether c may appear in an identifier after its first
+// character. Dashes are allowed so that header names need not be quoted.
+func isIdentChar(c rune) bool {
+	return c == '_' || c == '-' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// Parsing of policy expressions.
+
+type policyParser struct {
+	tokens []policyToken
+	pos    int
+}
+
+func (p *policyParser) peek() policyToken {
+	return p.tokens[p.pos]
+}
+
+func (p *policyParser) next() policyToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the given operator or keyword.
+func (p *policyParser) accept(text string) bool {
+	t := p.peek()
+	if (t.kind == tokOperator || t.kind == tokIdent) && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// unexpected describes an unexpected token.
+func (p *policyParser) unexpected(expected string) error {
+	t := p.peek()
+	return fmt.Errorf("unexpected %s at offset %d, expected %s", t.describe(), t.offset, expected)
+}
+
+// parseOr parses: and ( "||" and )*
+func (p *policyParser) parseOr() (policyNode, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.accept("||") {
+		var right policyNode
+		right, err = p.parseAnd()
+		left = &policyBinary{op: "||", left: left, right: right}
+	}
+	return left, err
+}
+
+// parseAnd parses: unary ( "&&" unary )*
+func (p *policyParser) parseAnd() (policyNode, error) {
+	left, err := p.parseUnary()
+	for err == nil && p.accept("&&") {
+		var right policyNode
+		right, err = p.parseUnary()
+		left = &policyBinary{op: "&&", left: left, right: right}
+	}
+	return left, err
+}
+
+// parseUnary parses: "!" unary | comparison
+func (p *policyParser) parseUnary() (policyNode, error) {
+	if p.accept("!") {
+		operand, err := p.parseUnary()
+		return &policyNot{operand: operand}, err
+	}
+	return p.parseComparison()
+}
+
+// parseComparison parses: primary ( ( "==" | "!=" | "in" ) primary )?
+func (p *policyParser) parseComparison() (policyNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "in"} {
+		if p.accept(op) {
+			right, err := p.parsePrimary()
+			return &policyBinary{op: op, left: left, right: right}, err
+		}
+	}
+	return left, nil
+}
+
+// parsePrimary parses a literal, a variable, a list or a parenthesized
+// expression.
+func (p *policyParser) parsePrimary() (policyNode, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokString:
+		p.next()
+		return &policyLiteral{value: t.text}, nil
+	case t.kind == tokNumber:
+		p.next()
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", t.text, t.offset)
+		}
+		return &policyLiteral{value: n}, nil
+	case t.kind == tokIdent:
+		switch t.text {
+		case "true", "false":
+			p.next()
+			return &policyLiteral{value: t.text == "true"}, nil
+		case "null":
+			p.next()
+			return &policyLiteral{value: nil}, nil
+		case "in":
+			return nil, p.unexpected("an operand")
+		}
+		return p.parseVariable()
+	case p.accept("("):
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.unexpected(`")"`)
+		}
+		return expr, nil
+	case p.accept("["):
+		list := &policyList{}
+		if p.accept("]") {
+			return list, nil
+		}
+		for {
+			item, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			list.items = append(list.items, item)
+			if p.accept("]") {
+				return list, nil
+			}
+			if !p.accept(",") {
+				return nil, p.unexpected(`"," or "]"`)
+			}
+		}
+	default:
+		return nil, p.unexpected("an operand")
+	}
+}
+
+// parseVariable parses: ident ( "." ident | "[" string "]" )*
+func (p *policyParser) parseVariable() (policyNode, error) {
+	t := p.next()
+	known := false
+	for _, name := range policyVariables {
+		known = known || name == t.text
+	}
+	if !known {
+		return nil, fmt.Errorf("unknown variable %q at offset %d, expected one of %s",
+			t.text, t.offset, strings.Join(policyVariables, ", "))
+	}
+
+	v := &policyVariable{name: t.text}
+	for {
+		switch {
+		case p.accept("."):
+			key := p.peek()
+			if key.kind != tokIdent {
+				return nil, p.unexpected("a name")
+			}
+			p.next()
+			v.path = append(v.path, key.text)
+		case p.peek().kind == tokOperator && p.peek().text == "[":
+			p.next()
+			key := p.peek()
+			if key.kind != tokString {
+				return nil, p.unexpected("a quoted name")
+			}
+			p.next()
+			if !p.accept("]") {
+				return nil, p.unexpected(`"]"`)
+			}
+			v.path = append(v.path, key.text)
+		default:
+			return v, nil
+		}
+	}
+}
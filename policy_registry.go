@@ -0,0 +1,135 @@
+package jwtauth
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/goadesign/goa"
+	"golang.org/x/net/context"
+)
+
+// AnyAction is a wildcard action name; rules that are registered for it apply
+// to every action of a controller.
+const AnyAction = "*"
+
+// PolicyRegistry associates authorization rules with specific goa actions, as
+// identified by goa.ContextController() and goa.ContextAction(). Its Authorize
+// method is an AuthorizationFunc that applies a base policy to every request,
+// then applies the rules that are registered for the requested action:
+//
+//     policies := &jwtauth.PolicyRegistry{}
+//     policies.Register("bottle", "delete", jwtauth.ClaimEquals("tenant", "acme"))
+//     middleware := jwtauth.New(scheme, store,
+//         jwtauth.Authorization(policies.Authorize),
+//     )
+//
+// The zero value is ready to use, and its base policy is DefaultAuthorization.
+// It is safe to register rules while the registry is in use.
+type PolicyRegistry struct {
+	// Base is applied to every request before any registered rules. If it is
+	// nil, DefaultAuthorization is applied.
+	Base AuthorizationFunc
+
+	mutex sync.RWMutex
+	rules map[string][]AuthorizationFunc
+}
+
+// Register adds rules for the given controller and action. Use AnyAction to
+// add rules for every action of the controller. All of an action's rules must
+// pass, in the order they were registered; rules for AnyAction come first.
+func (r *PolicyRegistry) Register(controller, action string, rules ...AuthorizationFunc) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.rules == nil {
+		r.rules = map[string][]AuthorizationFunc{}
+	}
+	key := policyKey(controller, action)
+	r.rules[key] = append(r.rules[key], rules...)
+}
+
+// Authorize applies the base policy, then the rules that are registered for
+// the requested action. If a rule fails, its error's "policy" metadata field
+// identifies it, e.g. "bottle#delete[0]".
+func (r *PolicyRegistry) Authorize(ctx context.Context, claims Claims) error {
+	base := r.Base
+	if base == nil {
+		base = DefaultAuthorization
+	}
+	if err := base(ctx, claims); err != nil {
+		return err
+	}
+
+	controller, action := goa.ContextController(ctx), goa.ContextAction(ctx)
+	for _, key := range []string{policyKey(controller, AnyAction), policyKey(controller, action)} {
+		for i, rule := range r.lookup(key) {
+			if err := rule(ctx, claims); err != nil {
+				return annotatePolicy(err, fmt.Sprintf("%s[%d]", key, i))
+			}
+		}
+	}
+	return nil
+}
+
+// lookup returns the rules that are registered under key.
+func (r *PolicyRegistry) lookup(key string) []AuthorizationFunc {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.rules[key]
+}
+
+// policyKey identifies an action in a PolicyRegistry.
+func policyKey(controller, action string) string {
+	return controller + "#" + action
+}
+
+// ClaimEquals creates an AuthorizationFunc that passes the request only if
+// the named claim equals one of the given values, e.g. to restrict an action
+// to the principals of certain tenants.
+func ClaimEquals(name string, values ...string) AuthorizationFunc {
+	return func(ctx context.Context, claims Claims) error {
+		if _, ok := claims[name]; ok {
+			actual := claims.String(name)
+			for _, v := range values {
+				if actual == v {
+					return nil
+				}
+			}
+		}
+		return ErrAuthorizationFailed("claim mismatch", "claim", name, "expected", values)
+	}
+}
+
+// TimeWindow creates an AuthorizationFunc that passes the request only during
+// a daily window, which begins start after midnight and ends end after
+// midnight in the given location. If end is before start, the window spans
+// midnight. For example, to allow an action only during business hours:
+//
+//     jwtauth.TimeWindow(9*time.Hour, 17*time.Hour, time.Local)
+//
+// The current time is determined by the middleware's Clock() option.
+func TimeWindow(start, end time.Duration, loc *time.Location) AuthorizationFunc {
+	return func(ctx context.Context, claims Claims) error {
+		now := time.Now
+		if oo := contextOptions(ctx); oo != nil && oo.Clock != nil {
+			now = oo.Clock
+		}
+
+		t := now().In(loc)
+		sinceMidnight := time.Duration(t.Hour())*time.Hour +
+			time.Duration(t.Minute())*time.Minute +
+			time.Duration(t.Second())*time.Second
+
+		var inside bool
+		if start <= end {
+			inside = sinceMidnight >= start && sinceMidnight < end
+		} else {
+			inside = sinceMidnight >= start || sinceMidnight < end
+		}
+		if !inside {
+			return ErrAuthorizationFailed("outside of time window", "start", start.String(), "end", end.String())
+		}
+		return nil
+	}
+}
@@ -0,0 +1,129 @@
+package jwtauth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/goadesign/goa"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rightscale/goa-jwtauth"
+)
+
+var _ = Describe("PolicyRegistry", func() {
+	var policies *jwtauth.PolicyRegistry
+	ctrl := goa.New("cellar").NewController("bottle")
+
+	action := func(name string, scopes ...string) context.Context {
+		ctx := goa.WithAction(ctrl.Context, name)
+		return goa.WithRequiredScopes(ctx, scopes)
+	}
+
+	BeforeEach(func() {
+		policies = &jwtauth.PolicyRegistry{}
+	})
+
+	It("applies DefaultAuthorization by default", func() {
+		claims := jwtauth.NewClaims("scopes", []string{"read"})
+
+		Ω(policies.Authorize(action("show", "read"), claims)).Should(Succeed())
+		Ω(policies.Authorize(action("show", "write"), claims)).Should(HaveResponseStatus(403))
+	})
+
+	It("applies a custom base policy", func() {
+		policies.Base = func(context.Context, jwtauth.Claims) error { return nil }
+
+		Ω(policies.Authorize(action("show", "write"), jwtauth.Claims{})).Should(Succeed())
+	})
+
+	It("applies rules to their action", func() {
+		policies.Register("bottle", "delete", jwtauth.ClaimEquals("tenant", "acme"))
+		acme := jwtauth.NewClaims("tenant", "acme")
+		other := jwtauth.NewClaims("tenant", "other")
+
+		Ω(policies.Authorize(action("delete"), acme)).Should(Succeed())
+		Ω(policies.Authorize(action("show"), other)).Should(Succeed())
+
+		err := policies.Authorize(action("delete"), other)
+		Ω(err).Should(HaveResponseStatus(403))
+		var gerr *goa.ErrorResponse
+		Ω(errors.As(err, &gerr)).Should(BeTrue())
+		Ω(gerr.Meta["policy"]).Should(Equal("bottle#delete[0]"))
+	})
+
+	It("applies rules to any action", func() {
+		policies.Register("bottle", jwtauth.AnyAction, jwtauth.ClaimEquals("tenant", "acme"))
+
+		Ω(policies.Authorize(action("show"), jwtauth.NewClaims("tenant", "acme"))).Should(Succeed())
+		Ω(policies.Authorize(action("show"), jwtauth.NewClaims())).Should(HaveResponseStatus(403))
+	})
+
+	It("requires every rule to pass", func() {
+		policies.Register("bottle", "delete", jwtauth.ClaimEquals("tenant", "acme"))
+		policies.Register("bottle", "delete", jwtauth.ClaimEquals("sub", "alice"))
+
+		Ω(policies.Authorize(action("delete"), jwtauth.NewClaims("tenant", "acme", "sub", "alice"))).Should(Succeed())
+		Ω(policies.Authorize(action("delete"), jwtauth.NewClaims("tenant", "acme", "sub", "bob"))).Should(HaveResponseStatus(403))
+	})
+
+	Context("ClaimEquals()", func() {
+		It("accepts any of the values", func() {
+			rule := jwtauth.ClaimEquals("tenant", "acme", "initech")
+
+			Ω(rule(context.Background(), jwtauth.NewClaims("tenant", "initech"))).Should(Succeed())
+			Ω(rule(context.Background(), jwtauth.NewClaims("tenant", "umbrella"))).Should(HaveResponseStatus(403))
+		})
+	})
+
+	Context("TimeWindow()", func() {
+		var now time.Time
+		var resp *httptest.ResponseRecorder
+		var req *http.Request
+
+		authorize := func(rule jwtauth.AuthorizationFunc) error {
+			stack := func(context.Context, http.ResponseWriter, *http.Request) error { return nil }
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1},
+				jwtauth.Authorization(rule),
+				jwtauth.Clock(func() time.Time { return now }))
+			return middleware(stack)(context.Background(), resp, req)
+		}
+
+		BeforeEach(func() {
+			resp = httptest.NewRecorder()
+			req, _ = http.NewRequest("GET", "http://example.com/", nil)
+		})
+
+		It("passes requests inside the window", func() {
+			rule := jwtauth.TimeWindow(9*time.Hour, 17*time.Hour, time.UTC)
+
+			now = time.Date(2016, 10, 1, 9, 0, 0, 0, time.UTC)
+			Ω(authorize(rule)).Should(Succeed())
+			now = time.Date(2016, 10, 1, 16, 59, 59, 0, time.UTC)
+			Ω(authorize(rule)).Should(Succeed())
+		})
+
+		It("forbids requests outside the window", func() {
+			rule := jwtauth.TimeWindow(9*time.Hour, 17*time.Hour, time.UTC)
+
+			now = time.Date(2016, 10, 1, 8, 59, 59, 0, time.UTC)
+			Ω(authorize(rule)).Should(HaveResponseStatus(403))
+			now = time.Date(2016, 10, 1, 17, 0, 0, 0, time.UTC)
+			Ω(authorize(rule)).Should(HaveResponseStatus(403))
+		})
+
+		It("spans midnight", func() {
+			rule := jwtauth.TimeWindow(22*time.Hour, 2*time.Hour, time.UTC)
+
+			now = time.Date(2016, 10, 1, 23, 0, 0, 0, time.UTC)
+			Ω(authorize(rule)).Should(Succeed())
+			now = time.Date(2016, 10, 1, 1, 0, 0, 0, time.UTC)
+			Ω(authorize(rule)).Should(Succeed())
+			now = time.Date(2016, 10, 1, 12, 0, 0, 0, time.UTC)
+			Ω(authorize(rule)).Should(HaveResponseStatus(403))
+		})
+	})
+})
@@ -0,0 +1,156 @@
+package jwtauth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"golang.org/x/net/context"
+
+	"github.com/goadesign/goa"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rightscale/goa-jwtauth"
+)
+
+var _ = Describe("Policy", func() {
+	claims := jwtauth.NewClaims(
+		"sub", "bob",
+		"tenant", "acme",
+		"level", float64(7),
+		"admin", false,
+		"scopes", []interface{}{"bottle:read", "bottle:drink"},
+		"https://acme.com/org", "cellar",
+		"address", map[string]interface{}{"country": "FR"},
+	)
+
+	evaluate := func(ctx context.Context, expr string) error {
+		p, err := jwtauth.CompilePolicy(expr)
+		Ω(err).ShouldNot(HaveOccurred())
+		return p.Authorize(ctx, claims)
+	}
+
+	allows := func(expr string) bool {
+		return evaluate(context.Background(), expr) == nil
+	}
+
+	Context("CompilePolicy()", func() {
+		It("describes syntax errors", func() {
+			_, err := jwtauth.CompilePolicy(`claims.sub == `)
+			Ω(err).Should(MatchError(HavePrefix(`policy "claims.sub == ": `)))
+			Ω(err).Should(MatchError(ContainSubstring("unexpected end of expression at offset 14, expected an operand")))
+
+			_, err = jwtauth.CompilePolicy(`(claims.sub == "bob"`)
+			Ω(err).Should(MatchError(ContainSubstring(`expected ")"`)))
+
+			_, err = jwtauth.CompilePolicy(`claims.sub = "bob"`)
+			Ω(err).Should(MatchError(ContainSubstring(`unexpected character '=' at offset 11`)))
+
+			_, err = jwtauth.CompilePolicy(`claims.sub == "bob`)
+			Ω(err).Should(MatchError(ContainSubstring("unterminated string at offset 14")))
+
+			_, err = jwtauth.CompilePolicy(`claims.sub "bob"`)
+			Ω(err).Should(MatchError(ContainSubstring(`unexpected "bob" at offset 11, expected end of expression`)))
+		})
+
+		It("rejects unknown variables", func() {
+			_, err := jwtauth.CompilePolicy(`claim.sub == "bob"`)
+			Ω(err).Should(MatchError(ContainSubstring(`unknown variable "claim" at offset 0`)))
+		})
+
+		It("panics in MustCompilePolicy()", func() {
+			Ω(func() { jwtauth.MustCompilePolicy(`&&`) }).Should(Panic())
+		})
+	})
+
+	It("compares claims", func() {
+		Ω(allows(`claims.sub == "bob"`)).Should(BeTrue())
+		Ω(allows(`claims.sub == 'alice'`)).Should(BeFalse())
+		Ω(allows(`claims.sub != "alice"`)).Should(BeTrue())
+		Ω(allows(`claims.level == 7`)).Should(BeTrue())
+		Ω(allows(`claims.admin == false`)).Should(BeTrue())
+		Ω(allows(`claims["https://acme.com/org"] == "cellar"`)).Should(BeTrue())
+		Ω(allows(`claims.address.country == "FR"`)).Should(BeTrue())
+	})
+
+	It("treats absent claims as null", func() {
+		Ω(allows(`claims.missing == null`)).Should(BeTrue())
+		Ω(allows(`claims.missing != null`)).Should(BeFalse())
+		Ω(allows(`claims.sub != null`)).Should(BeTrue())
+		Ω(allows(`null == null`)).Should(BeTrue())
+		Ω(allows(`claims.missing`)).Should(BeFalse())
+		Ω(allows(`claims.missing.deeper == "x"`)).Should(BeFalse())
+	})
+
+	It("never matches absent values", func() {
+		Ω(allows(`claims.missing == claims.absent`)).Should(BeFalse())
+		Ω(allows(`claims.missing != claims.absent`)).Should(BeFalse())
+		Ω(allows(`claims.missing != "bob"`)).Should(BeFalse())
+		Ω(allows(`claims.missing in ["bob"]`)).Should(BeFalse())
+		Ω(allows(`claims.missing in [claims.absent]`)).Should(BeFalse())
+		Ω(allows(`[claims.missing] == [claims.absent]`)).Should(BeFalse())
+	})
+
+	It("denies when both sides of a binding are absent", func() {
+		req, _ := http.NewRequest("GET", "http://example.com/tenants", nil)
+		ctx := goa.NewContext(context.Background(), httptest.NewRecorder(), req, url.Values{})
+
+		err := jwtauth.MustCompilePolicy(`claims.tenant == params.tenant`).Authorize(ctx, jwtauth.NewClaims("sub", "bob"))
+		Ω(err).Should(HaveResponseStatus(403))
+	})
+
+	It("tests membership", func() {
+		Ω(allows(`"bottle:drink" in scopes`)).Should(BeTrue())
+		Ω(allows(`"bottle:smash" in scopes`)).Should(BeFalse())
+		Ω(allows(`claims.tenant in ["acme", "initech"]`)).Should(BeTrue())
+		Ω(allows(`"bottle:read" in claims.scopes`)).Should(BeTrue())
+	})
+
+	It("combines conditions", func() {
+		Ω(allows(`claims.sub == "bob" && claims.tenant == "acme"`)).Should(BeTrue())
+		Ω(allows(`claims.sub == "alice" || claims.tenant == "acme"`)).Should(BeTrue())
+		Ω(allows(`!claims.admin && !(claims.sub == "alice")`)).Should(BeTrue())
+		Ω(allows(`claims.sub == "alice" || claims.admin && true`)).Should(BeFalse())
+		Ω(allows(`(claims.sub == "bob" || claims.admin) && claims.tenant == "initech"`)).Should(BeFalse())
+	})
+
+	It("exposes required scopes and goa request data", func() {
+		req, _ := http.NewRequest("GET", "http://example.com/tenants/acme", nil)
+		ctx := goa.NewContext(context.Background(), httptest.NewRecorder(), req, url.Values{"tenant": {"acme"}})
+		ctx = goa.WithRequiredScopes(ctx, []string{"bottle:drink"})
+
+		Ω(evaluate(ctx, `claims.tenant == params.tenant`)).Should(Succeed())
+		Ω(evaluate(ctx, `"bottle:drink" in required`)).Should(Succeed())
+		Ω(evaluate(ctx, `"bottle:drink" in scopes`)).Should(Succeed())
+	})
+
+	It("exposes the goa controller and action", func() {
+		ctrl := goa.New("cellar").NewController("bottle")
+		ctx := goa.WithAction(ctrl.Context, "drink")
+
+		Ω(evaluate(ctx, `controller == "bottle" && action == "drink"`)).Should(Succeed())
+	})
+
+	It("denies with ErrAuthorizationFailed", func() {
+		err := evaluate(context.Background(), `claims.sub == "alice"`)
+		Ω(err).Should(HaveResponseStatus(403))
+		Ω(err.Error()).Should(ContainSubstring(`claims.sub == "alice"`))
+	})
+
+	Context("in a middleware", func() {
+		It("exposes the request", func() {
+			policy := jwtauth.MustCompilePolicy(`request.method == "POST" && request.header.X-Tenant == claims.iss`)
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1},
+				jwtauth.Authorization(policy.Authorize))
+			stack := middleware(func(context.Context, http.ResponseWriter, *http.Request) error { return nil })
+
+			req, _ := http.NewRequest("POST", "http://example.com/", nil)
+			setBearerHeader(req, makeToken("acme", "bob", hmacKey1))
+			req.Header.Set("X-Tenant", "acme")
+			Ω(stack(context.Background(), httptest.NewRecorder(), req)).Should(Succeed())
+
+			req.Header.Set("X-Tenant", "initech")
+			Ω(stack(context.Background(), httptest.NewRecorder(), req)).Should(HaveResponseStatus(403))
+		})
+	})
+})
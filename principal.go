@@ -0,0 +1,54 @@
+package jwtauth
+
+import "golang.org/x/net/context"
+
+type (
+	// Principal describes the party that a request's JWT authenticates. It
+	// allows handlers to identify the caller without knowing which claims a
+	// given issuer uses to convey each piece of information.
+	Principal struct {
+		// Subject identifies the principal; it is the "sub" claim by default.
+		Subject string
+		// Issuer identifies the party that vouches for the principal; it is
+		// the "iss" claim by default.
+		Issuer string
+		// Scopes lists the principal's authorization scopes.
+		Scopes []string
+		// Tenant identifies the organization to which the principal
+		// belongs, if any.
+		Tenant string
+		// Name is the principal's human-readable display name, if any.
+		Name string
+		// Claims contains all of the token's claims.
+		Claims Claims
+	}
+)
+
+// DefaultPrincipal is the default principal-mapping method. It builds a
+// Principal from registered and commonly used claims:
+//
+// Subject and Issuer come from the "sub" and "iss" claims; Scopes from
+// ClaimedScopes(); Tenant from the "tenant" claim, or the "tid" claim if there
+// is no "tenant" claim; and Name from the "name" claim, or the
+// "preferred_username" claim if there is no "name" claim.
+func DefaultPrincipal(ctx context.Context, claims Claims) (*Principal, error) {
+	return &Principal{
+		Subject: claims.Subject(),
+		Issuer:  claims.Issuer(),
+		Scopes:  ClaimedScopes(ctx, claims),
+		Tenant:  firstClaim(claims, "tenant", "tid"),
+		Name:    firstClaim(claims, "name", "preferred_username"),
+		Claims:  claims,
+	}, nil
+}
+
+// firstClaim returns the first of the named claims that is present, as a
+// string.
+func firstClaim(claims Claims, names ...string) string {
+	for _, name := range names {
+		if _, ok := claims[name]; ok {
+			return claims.String(name)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+package jwtauth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"golang.org/x/net/context"
+
+	"github.com/goadesign/goa"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rightscale/goa-jwtauth"
+)
+
+var _ = Describe("DefaultPrincipal()", func() {
+	It("maps standard claims", func() {
+		claims := jwtauth.NewClaims("iss", "alice", "sub", "bob", "scopes", []string{"read", "write"},
+			"tid", "acme", "name", "Bob Smith")
+
+		p, err := jwtauth.DefaultPrincipal(context.Background(), claims)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(p.Issuer).Should(Equal("alice"))
+		Ω(p.Subject).Should(Equal("bob"))
+		Ω(p.Scopes).Should(Equal([]string{"read", "write"}))
+		Ω(p.Tenant).Should(Equal("acme"))
+		Ω(p.Name).Should(Equal("Bob Smith"))
+		Ω(p.Claims).Should(Equal(claims))
+	})
+
+	It("prefers the tenant and name claims", func() {
+		claims := jwtauth.NewClaims("tenant", "acme", "tid", "1234",
+			"name", "Bob Smith", "preferred_username", "bob")
+
+		p, _ := jwtauth.DefaultPrincipal(context.Background(), claims)
+		Ω(p.Tenant).Should(Equal("acme"))
+		Ω(p.Name).Should(Equal("Bob Smith"))
+
+		delete(claims, "name")
+		p, _ = jwtauth.DefaultPrincipal(context.Background(), claims)
+		Ω(p.Name).Should(Equal("bob"))
+	})
+})
+
+var _ = Describe("Principal mapping", func() {
+	var resp *httptest.ResponseRecorder
+	var req *http.Request
+	var principal *jwtauth.Principal
+	var stack goa.Handler
+
+	BeforeEach(func() {
+		resp = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "http://example.com/", nil)
+		principal = nil
+		stack = func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+			principal = jwtauth.ContextPrincipal(ctx)
+			return nil
+		}
+	})
+
+	It("stores the principal in the context", func() {
+		middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1})
+		setBearerHeader(req, makeToken("alice", "bob", hmacKey1, "read"))
+
+		Ω(middleware(stack)(context.Background(), resp, req)).Should(Succeed())
+		Ω(principal).ShouldNot(BeNil())
+		Ω(principal.Subject).Should(Equal("bob"))
+		Ω(principal.Scopes).Should(Equal([]string{"read"}))
+	})
+
+	It("stores no principal when there is no token", func() {
+		middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1})
+
+		Ω(middleware(stack)(context.Background(), resp, req)).Should(Succeed())
+		Ω(principal).Should(BeNil())
+	})
+
+	It("uses a custom mapping", func() {
+		mapping := func(ctx context.Context, claims jwtauth.Claims) (*jwtauth.Principal, error) {
+			return &jwtauth.Principal{Subject: "user:" + claims.Subject()}, nil
+		}
+		middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1},
+			jwtauth.PrincipalMapping(mapping))
+		setBearerHeader(req, makeToken("alice", "bob", hmacKey1))
+
+		Ω(middleware(stack)(context.Background(), resp, req)).Should(Succeed())
+		Ω(principal.Subject).Should(Equal("user:bob"))
+	})
+
+	It("fails when the mapping fails", func() {
+		failure := errors.New("no such user")
+		mapping := func(ctx context.Context, claims jwtauth.Claims) (*jwtauth.Principal, error) {
+			return nil, failure
+		}
+		middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1},
+			jwtauth.PrincipalMapping(mapping))
+		setBearerHeader(req, makeToken("alice", "bob", hmacKey1))
+
+		Ω(middleware(stack)(context.Background(), resp, req)).Should(Equal(failure))
+		Ω(principal).Should(BeNil())
+	})
+})
@@ -0,0 +1,154 @@
+package jwtauth
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/goadesign/goa"
+	"golang.org/x/net/context"
+)
+
+// RequestRules creates a RequestAuthorizationFunc that passes the request only
+// if every one of the given rules passes it. The rules are applied in order,
+// and the first failure is returned with a "policy" metadata field, such as
+// "request[1]", that identifies the rule that failed.
+func RequestRules(rules ...RequestAuthorizationFunc) RequestAuthorizationFunc {
+	return func(ctx context.Context, req *http.Request, claims Claims) error {
+		for i, rule := range rules {
+			if err := rule(ctx, req, claims); err != nil {
+				return annotatePolicy(err, fmt.Sprintf("request[%d]", i))
+			}
+		}
+		return nil
+	}
+}
+
+// RequestPolicy adapts a RequestAuthorizationFunc to an AuthorizationFunc,
+// so that request-aware rules can be combined with AllOf() and friends or
+// registered in a PolicyRegistry. The resulting function fails with
+// ErrUnsupported unless it is called by a jwtauth middleware.
+func RequestPolicy(fn RequestAuthorizationFunc) AuthorizationFunc {
+	return func(ctx context.Context, claims Claims) error {
+		req := contextRequest(ctx)
+		if req == nil {
+			return ErrUnsupported("request is unavailable outside of jwtauth middleware")
+		}
+		return fn(ctx, req, claims)
+	}
+}
+
+// ClaimMatchesHeader creates a RequestAuthorizationFunc that passes the request
+// only if the named claim equals the value of the named header, e.g. to ensure
+// that the "tenant" claim matches the X-Tenant header.
+func ClaimMatchesHeader(claim, header string) RequestAuthorizationFunc {
+	return func(ctx context.Context, req *http.Request, claims Claims) error {
+		return matchClaim(claims, claim, req.Header.Get(header), "header", header)
+	}
+}
+
+// ClaimMatchesParam creates a RequestAuthorizationFunc that passes the request
+// only if the named claim equals the value of the named request parameter. For
+// goa actions, the parameters are those of goa.ContextRequest(), which include
+// path and query-string parameters; otherwise, only the query string is
+// consulted.
+func ClaimMatchesParam(claim, param string) RequestAuthorizationFunc {
+	return func(ctx context.Context, req *http.Request, claims Claims) error {
+		var value string
+		if rd := goa.ContextRequest(ctx); rd != nil && rd.Params != nil {
+			value = rd.Params.Get(param)
+		} else {
+			value = req.URL.Query().Get(param)
+		}
+		return matchClaim(claims, claim, value, "param", param)
+	}
+}
+
+// ClaimMatchesPath creates a RequestAuthorizationFunc that binds claims to
+// segments of the request path. The pattern consists of literal segments,
+// "*" segments that match any value, and "{claim}" segments that must equal
+// the named claim:
+//
+//     jwtauth.ClaimMatchesPath("/users/{sub}")
+//
+// The pattern selects the requests to which it applies: if the literal
+// segments do not match a prefix of the request path, the rule passes the
+// request. In the example above, a token whose subject is "bob" may access
+// /users/bob and /users/bob/bottles, but not /users/alice, and the rule does
+// not restrict access to /bottles. Literal segments are compared without
+// regard to case, so that /USERS/alice cannot evade the rule if the router
+// is case-insensitive; claims must match exactly.
+//
+// The rule splits the request path as it was sent, before percent-decoding,
+// and then decodes each segment. It denies every request whose path has a "."
+// or ".." segment, or a segment that contains an encoded slash, since the
+// router may not dispatch such a request on the segments that the rule sees.
+func ClaimMatchesPath(pattern string) RequestAuthorizationFunc {
+	segments := pathSegments(pattern)
+
+	return func(ctx context.Context, req *http.Request, claims Claims) error {
+		path, ok := requestSegments(req)
+		if !ok {
+			return ErrAuthorizationFailed("ambiguous request path", "path", pattern)
+		}
+		if len(path) < len(segments) {
+			return nil
+		}
+
+		// first decide whether the pattern applies, then check the claims
+		for i, seg := range segments {
+			if seg != "*" && !isPathClaim(seg) && !strings.EqualFold(seg, path[i]) {
+				return nil
+			}
+		}
+		for i, seg := range segments {
+			if isPathClaim(seg) {
+				claim := seg[1 : len(seg)-1]
+				if err := matchClaim(claims, claim, path[i], "path", pattern); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// matchClaim ensures that the named claim is present and equal to value.
+func matchClaim(claims Claims, claim, value, kind, name string) error {
+	if _, ok := claims[claim]; !ok || value == "" || claims.String(claim) != value {
+		return ErrAuthorizationFailed("claim does not match request", "claim", claim, kind, name)
+	}
+	return nil
+}
+
+// pathSegments splits a URL path into its non-empty segments.
+func pathSegments(path string) []string {
+	var segments []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// requestSegments splits the escaped path of a request into its non-empty
+// segments and decodes each of them. It returns false if a segment cannot be
+// decoded, is a dot segment, or contains a slash once decoded.
+func requestSegments(req *http.Request) ([]string, bool) {
+	segments := pathSegments(req.URL.EscapedPath())
+	for i, seg := range segments {
+		decoded, err := url.PathUnescape(seg)
+		if err != nil || decoded == "." || decoded == ".." || strings.Contains(decoded, "/") {
+			return nil, false
+		}
+		segments[i] = decoded
+	}
+	return segments, true
+}
+
+// isPathClaim determines whether a pattern segment names a claim.
+func isPathClaim(segment string) bool {
+	return len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
@@ -0,0 +1,161 @@
+package jwtauth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"golang.org/x/net/context"
+
+	"github.com/goadesign/goa"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rightscale/goa-jwtauth"
+)
+
+var _ = Describe("Request rules", func() {
+	ctx := context.Background()
+	bob := jwtauth.NewClaims("sub", "bob", "tenant", "acme")
+
+	request := func(path string) *http.Request {
+		req, _ := http.NewRequest("GET", "http://example.com"+path, nil)
+		return req
+	}
+
+	Context("ClaimMatchesHeader()", func() {
+		rule := jwtauth.ClaimMatchesHeader("tenant", "X-Tenant")
+
+		It("passes matching requests", func() {
+			req := request("/")
+			req.Header.Set("X-Tenant", "acme")
+			Ω(rule(ctx, req, bob)).Should(Succeed())
+		})
+
+		It("forbids other requests", func() {
+			req := request("/")
+			Ω(rule(ctx, req, bob)).Should(HaveResponseStatus(403))
+
+			req.Header.Set("X-Tenant", "initech")
+			Ω(rule(ctx, req, bob)).Should(HaveResponseStatus(403))
+			Ω(rule(ctx, req, jwtauth.NewClaims())).Should(HaveResponseStatus(403))
+		})
+	})
+
+	Context("ClaimMatchesParam()", func() {
+		rule := jwtauth.ClaimMatchesParam("tenant", "tenant")
+
+		It("uses goa params", func() {
+			req := request("/?tenant=initech")
+			gctx := goa.NewContext(ctx, httptest.NewRecorder(), req, url.Values{"tenant": {"acme"}})
+
+			Ω(rule(gctx, req, bob)).Should(Succeed())
+		})
+
+		It("uses the query string outside of goa", func() {
+			Ω(rule(ctx, request("/?tenant=acme"), bob)).Should(Succeed())
+			Ω(rule(ctx, request("/?tenant=initech"), bob)).Should(HaveResponseStatus(403))
+		})
+	})
+
+	Context("ClaimMatchesPath()", func() {
+		rule := jwtauth.ClaimMatchesPath("/users/{sub}")
+
+		It("passes matching paths", func() {
+			Ω(rule(ctx, request("/users/bob"), bob)).Should(Succeed())
+			Ω(rule(ctx, request("/users/bob/bottles"), bob)).Should(Succeed())
+		})
+
+		It("forbids mismatched paths", func() {
+			Ω(rule(ctx, request("/users/alice"), bob)).Should(HaveResponseStatus(403))
+			Ω(rule(ctx, request("/users/alice/bottles"), bob)).Should(HaveResponseStatus(403))
+		})
+
+		It("compares literal segments without regard to case", func() {
+			Ω(rule(ctx, request("/Users/alice"), bob)).Should(HaveResponseStatus(403))
+			Ω(rule(ctx, request("/USERS/alice/bottles"), bob)).Should(HaveResponseStatus(403))
+			Ω(rule(ctx, request("/Users/bob"), bob)).Should(Succeed())
+			Ω(rule(ctx, request("/users/Bob"), bob)).Should(HaveResponseStatus(403))
+		})
+
+		It("decodes each segment of the path as sent", func() {
+			Ω(rule(ctx, request("/users/b%6Fb"), bob)).Should(Succeed())
+			Ω(rule(ctx, request("/users/al%69ce"), bob)).Should(HaveResponseStatus(403))
+		})
+
+		It("forbids encoded slashes and dot segments", func() {
+			Ω(rule(ctx, request("/users/bob%2F..%2Falice/bottles"), bob)).Should(HaveResponseStatus(403))
+			Ω(rule(ctx, request("/users/bob/../alice"), bob)).Should(HaveResponseStatus(403))
+			Ω(rule(ctx, request("/users/%2E%2E/alice"), bob)).Should(HaveResponseStatus(403))
+			Ω(rule(ctx, request("/bottles/a%2Fb"), bob)).Should(HaveResponseStatus(403))
+		})
+
+		It("ignores unrelated paths", func() {
+			Ω(rule(ctx, request("/bottles/alice"), bob)).Should(Succeed())
+			Ω(rule(ctx, request("/users"), bob)).Should(Succeed())
+		})
+
+		It("supports wildcards and several claims", func() {
+			rule := jwtauth.ClaimMatchesPath("/*/{tenant}/users/{sub}")
+
+			Ω(rule(ctx, request("/v1/acme/users/bob"), bob)).Should(Succeed())
+			Ω(rule(ctx, request("/v1/initech/users/bob"), bob)).Should(HaveResponseStatus(403))
+		})
+	})
+
+	Context("RequestRules()", func() {
+		It("requires every rule to pass", func() {
+			rules := jwtauth.RequestRules(
+				jwtauth.ClaimMatchesPath("/users/{sub}"),
+				jwtauth.ClaimMatchesHeader("tenant", "X-Tenant"),
+			)
+			req := request("/users/bob")
+			req.Header.Set("X-Tenant", "acme")
+			Ω(rules(ctx, req, bob)).Should(Succeed())
+
+			req.Header.Set("X-Tenant", "initech")
+			err := rules(ctx, req, bob)
+			var gerr *goa.ErrorResponse
+			Ω(errors.As(err, &gerr)).Should(BeTrue())
+			Ω(gerr.Meta["policy"]).Should(Equal("request[1]"))
+		})
+	})
+
+	Context("in a middleware", func() {
+		var resp *httptest.ResponseRecorder
+		stack := func(context.Context, http.ResponseWriter, *http.Request) error { return nil }
+
+		BeforeEach(func() {
+			resp = httptest.NewRecorder()
+		})
+
+		It("applies request authorization", func() {
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1},
+				jwtauth.RequestAuthorization(jwtauth.ClaimMatchesPath("/users/{sub}")))
+
+			req := request("/users/bob")
+			setBearerHeader(req, makeToken("alice", "bob", hmacKey1))
+			Ω(middleware(stack)(ctx, resp, req)).Should(Succeed())
+
+			req = request("/users/alice")
+			setBearerHeader(req, makeToken("alice", "bob", hmacKey1))
+			Ω(middleware(stack)(ctx, resp, req)).Should(HaveResponseStatus(403))
+		})
+
+		It("adapts request rules to policies", func() {
+			policy := jwtauth.AllOf(jwtauth.DefaultAuthorization,
+				jwtauth.RequestPolicy(jwtauth.ClaimMatchesPath("/users/{sub}")))
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1},
+				jwtauth.Authorization(policy))
+
+			req := request("/users/alice")
+			setBearerHeader(req, makeToken("alice", "bob", hmacKey1))
+			Ω(middleware(stack)(ctx, resp, req)).Should(HaveResponseStatus(403))
+		})
+
+		It("fails policies outside of a middleware", func() {
+			policy := jwtauth.RequestPolicy(jwtauth.ClaimMatchesPath("/users/{sub}"))
+			Ω(policy(ctx, bob)).Should(HaveResponseStatus(500))
+		})
+	})
+})
@@ -0,0 +1,68 @@
+package jwtauth
+
+import (
+	"encoding/json"
+
+	"golang.org/x/net/context"
+)
+
+// RolesClaim is the claim from which RoleAuthorization reads a token's roles.
+// It may be either an array of role names or a space-delimited string.
+const RolesClaim = "roles"
+
+// RoleMapping maps role names to the scopes that each role grants. It allows
+// tokens that carry coarse-grained roles to satisfy the fine-grained scopes
+// that goa actions require.
+type RoleMapping map[string][]string
+
+// LoadRoleMapping parses a RoleMapping from a JSON object whose keys are role
+// names and whose values are arrays of scopes:
+//
+//     {"sommelier": ["bottle:read", "bottle:drink"], "admin": ["bottle:*"]}
+//
+// Scopes are compared by the middleware's ScopeMatcher, so a wildcard such as
+// "bottle:*" grants every "bottle:" scope only if the middleware uses the
+// ScopeMatching(WildcardScopes) option; with the default ExactScopes, it
+// satisfies only a required scope that is literally "bottle:*".
+func LoadRoleMapping(data []byte) (RoleMapping, error) {
+	var mapping RoleMapping
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+// Scopes returns the scopes that the given roles grant. Roles that are not in
+// the mapping grant no scopes. Scopes granted by several roles are returned
+// once.
+func (m RoleMapping) Scopes(roles []string) []string {
+	var scopes []string
+	seen := map[string]bool{}
+	for _, role := range roles {
+		for _, scope := range m[role] {
+			if !seen[scope] {
+				seen[scope] = true
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
+
+// RoleAuthorization creates an AuthorizationFunc that behaves like
+// DefaultAuthorization, except that the token's roles (see RolesClaim) are
+// expanded into scopes using the given mapping before they are compared with
+// the context's required scopes. The token's claimed scopes, if any, are
+// honored as well.
+//
+//     mapping, err := jwtauth.LoadRoleMapping(roles)
+//     middleware := jwtauth.New(scheme, store,
+//         jwtauth.Authorization(jwtauth.RoleAuthorization(mapping)),
+//     )
+func RoleAuthorization(mapping RoleMapping) AuthorizationFunc {
+	return func(ctx context.Context, claims Claims) error {
+		held := ClaimedScopes(ctx, claims)
+		held = append(held, mapping.Scopes(claimList(claims, RolesClaim))...)
+		return authorizeScopes(ctx, held)
+	}
+}
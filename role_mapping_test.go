@@ -0,0 +1,54 @@
+package jwtauth_test
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/goadesign/goa"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rightscale/goa-jwtauth"
+)
+
+var _ = Describe("RoleMapping", func() {
+	mapping := jwtauth.RoleMapping{
+		"sommelier": {"bottle:read", "bottle:drink"},
+		"reader":    {"bottle:read"},
+	}
+
+	Context("LoadRoleMapping()", func() {
+		It("parses JSON", func() {
+			m, err := jwtauth.LoadRoleMapping([]byte(`{"sommelier": ["bottle:read", "bottle:drink"], "reader": ["bottle:read"]}`))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(m).Should(Equal(mapping))
+		})
+
+		It("rejects malformed JSON", func() {
+			_, err := jwtauth.LoadRoleMapping([]byte(`{"sommelier": "bottle:read"}`))
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+
+	It("expands roles into scopes", func() {
+		Ω(mapping.Scopes([]string{"reader", "sommelier", "janitor"})).Should(Equal([]string{"bottle:read", "bottle:drink"}))
+		Ω(mapping.Scopes(nil)).Should(BeEmpty())
+	})
+
+	Context("RoleAuthorization()", func() {
+		authz := jwtauth.RoleAuthorization(mapping)
+		ctx := goa.WithRequiredScopes(context.Background(), []string{"bottle:drink"})
+
+		It("passes tokens whose roles grant the required scopes", func() {
+			Ω(authz(ctx, jwtauth.NewClaims("roles", []interface{}{"sommelier"}))).Should(Succeed())
+			Ω(authz(ctx, jwtauth.NewClaims("roles", "reader sommelier"))).Should(Succeed())
+		})
+
+		It("forbids tokens whose roles do not grant the required scopes", func() {
+			Ω(authz(ctx, jwtauth.NewClaims("roles", []interface{}{"reader"}))).Should(HaveResponseStatus(403))
+			Ω(authz(ctx, jwtauth.NewClaims())).Should(HaveResponseStatus(403))
+		})
+
+		It("honors claimed scopes", func() {
+			Ω(authz(ctx, jwtauth.NewClaims("scopes", []string{"bottle:drink"}))).Should(Succeed())
+		})
+	})
+})
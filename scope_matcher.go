@@ -0,0 +1,95 @@
+package jwtauth
+
+import "strings"
+
+type (
+	// ScopeMatcher decides whether a scope that a token holds satisfies a
+	// scope that an action requires. DefaultAuthorization uses the matcher
+	// that is selected with the ScopeMatching() option.
+	ScopeMatcher interface {
+		Satisfies(held, required string) bool
+	}
+
+	// ScopeMatcherFunc is an adapter that allows the use of an ordinary
+	// function as a ScopeMatcher.
+	ScopeMatcherFunc func(held, required string) bool
+
+	// ScopeGraph is a ScopeMatcher for hierarchical scope models. It maps a
+	// scope to the scopes that it implies; implication is transitive, so if
+	// "admin" implies "bottle:*" and "bottle:*" implies "cellar:read", then
+	// "admin" satisfies "cellar:read".
+	//
+	// Scopes are compared using WildcardScopes, so a scope also satisfies
+	// everything that its implied wildcards cover:
+	//
+	//     ScopeGraph{"admin": {"bottle:*", "user:*"}}
+	ScopeGraph map[string][]string
+)
+
+var (
+	// ExactScopes is a ScopeMatcher that is satisfied only if the held and
+	// required scopes are equal. It is the default ScopeMatcher.
+	ExactScopes ScopeMatcherFunc = func(held, required string) bool {
+		return held == required
+	}
+
+	// WildcardScopes is a ScopeMatcher that, in addition to equal scopes,
+	// allows a held scope that ends in "*" to satisfy every required scope
+	// that begins with the same prefix. For example, "bottle:*" satisfies
+	// "bottle:drink" (but not "bottle"), and "*" satisfies any scope.
+	WildcardScopes ScopeMatcherFunc = func(held, required string) bool {
+		if held == required {
+			return true
+		}
+		if strings.HasSuffix(held, "*") {
+			prefix := strings.TrimSuffix(held, "*")
+			return len(required) > len(prefix) && strings.HasPrefix(required, prefix)
+		}
+		return false
+	}
+)
+
+// Satisfies calls fn(held, required).
+func (fn ScopeMatcherFunc) Satisfies(held, required string) bool {
+	return fn(held, required)
+}
+
+// Satisfies determines whether held, or any scope that it implies, satisfies
+// required.
+func (g ScopeGraph) Satisfies(held, required string) bool {
+	visited := map[string]bool{}
+	pending := []string{held}
+	for len(pending) > 0 {
+		scope := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if visited[scope] {
+			continue
+		}
+		visited[scope] = true
+
+		if WildcardScopes(scope, required) {
+			return true
+		}
+		pending = append(pending, g[scope]...)
+	}
+	return false
+}
+
+// satisfiesAll determines whether the held scopes satisfy every required
+// scope according to m. It returns the required scopes that are missing.
+func satisfiesAll(m ScopeMatcher, held, required []string) []string {
+	var missing []string
+	for _, r := range required {
+		found := false
+		for _, h := range held {
+			if m.Satisfies(h, r) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,101 @@
+package jwtauth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"golang.org/x/net/context"
+
+	"github.com/goadesign/goa"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rightscale/goa-jwtauth"
+)
+
+var _ = Describe("ScopeMatcher", func() {
+	Context("ExactScopes", func() {
+		It("matches equal scopes", func() {
+			Ω(jwtauth.ExactScopes.Satisfies("bottle:drink", "bottle:drink")).Should(BeTrue())
+			Ω(jwtauth.ExactScopes.Satisfies("bottle:*", "bottle:drink")).Should(BeFalse())
+		})
+	})
+
+	Context("WildcardScopes", func() {
+		It("matches equal scopes", func() {
+			Ω(jwtauth.WildcardScopes.Satisfies("bottle:drink", "bottle:drink")).Should(BeTrue())
+			Ω(jwtauth.WildcardScopes.Satisfies("bottle:drink", "bottle:eat")).Should(BeFalse())
+		})
+
+		It("matches wildcards", func() {
+			Ω(jwtauth.WildcardScopes.Satisfies("bottle:*", "bottle:drink")).Should(BeTrue())
+			Ω(jwtauth.WildcardScopes.Satisfies("bottle:*", "bottle:drink:fast")).Should(BeTrue())
+			Ω(jwtauth.WildcardScopes.Satisfies("*", "anything")).Should(BeTrue())
+		})
+
+		It("does not match the wildcard's parent", func() {
+			Ω(jwtauth.WildcardScopes.Satisfies("bottle:*", "bottle")).Should(BeFalse())
+			Ω(jwtauth.WildcardScopes.Satisfies("bottle:*", "bottles:drink")).Should(BeFalse())
+		})
+
+		It("treats required wildcards literally", func() {
+			Ω(jwtauth.WildcardScopes.Satisfies("bottle:drink", "bottle:*")).Should(BeFalse())
+		})
+	})
+
+	Context("ScopeGraph", func() {
+		graph := jwtauth.ScopeGraph{
+			"admin":       {"bottle:*", "operator"},
+			"operator":    {"cellar:read"},
+			"cellar:read": {"admin"}, // cycles are harmless
+		}
+
+		It("matches equal scopes", func() {
+			Ω(graph.Satisfies("user", "user")).Should(BeTrue())
+		})
+
+		It("follows implications transitively", func() {
+			Ω(graph.Satisfies("admin", "operator")).Should(BeTrue())
+			Ω(graph.Satisfies("admin", "cellar:read")).Should(BeTrue())
+			Ω(graph.Satisfies("operator", "cellar:read")).Should(BeTrue())
+		})
+
+		It("matches implied wildcards", func() {
+			Ω(graph.Satisfies("admin", "bottle:drink")).Should(BeTrue())
+			Ω(graph.Satisfies("operator", "bottle:drink")).Should(BeTrue())
+		})
+
+		It("does not match unrelated scopes", func() {
+			Ω(graph.Satisfies("admin", "billing")).Should(BeFalse())
+			Ω(graph.Satisfies("user", "cellar:read")).Should(BeFalse())
+		})
+	})
+
+	Context("in a middleware", func() {
+		var resp *httptest.ResponseRecorder
+		var req *http.Request
+		var stack goa.Handler
+		ctx := goa.WithRequiredScopes(context.Background(), []string{"bottle:drink"})
+
+		BeforeEach(func() {
+			resp = httptest.NewRecorder()
+			req, _ = http.NewRequest("GET", "http://example.com/", nil)
+			stack = func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				return nil
+			}
+			setBearerHeader(req, makeToken("alice", "bob", hmacKey1, "bottle:*"))
+		})
+
+		It("matches exactly by default", func() {
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1})
+
+			Ω(middleware(stack)(ctx, resp, req)).Should(HaveResponseStatus(403))
+		})
+
+		It("uses the selected matcher", func() {
+			middleware := jwtauth.New(commonScheme, &jwtauth.SimpleKeystore{hmacKey1},
+				jwtauth.ScopeMatching(jwtauth.WildcardScopes))
+
+			Ω(middleware(stack)(ctx, resp, req)).Should(Succeed())
+		})
+	})
+})